@@ -0,0 +1,595 @@
+package zkstarks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"github.com/actuallyachraf/algebra/poly"
+)
+
+// An AIR (algebraic intermediate representation) describes a computation by
+// the constraints its trace satisfies over the trace domain G = <g> of order
+// n, a randomized AIR with preprocessing (RAP) splits the trace in two
+// segments :
+// - the main segment is committed before any challenge is drawn
+// - challenges are drawn from the channel and the AIR builds the auxiliary
+// segment from the main one and the challenges (running products of
+// permutation and lookup arguments...) which is committed separately.
+// Constraints are evaluated on frames holding the rows of both segments so
+// they may span the main and auxiliary columns and use the challenges.
+// Transition constraints hold between every row and the next but the last
+// one and are divided by (X^n - 1) / (X - g^(n-1)), wrapping transitions
+// also hold between the last row and the first one (g^n = 1) and are divided
+// by X^n - 1, boundary constraints fix a column at a row and are divided by
+// X - g^row.
+
+// Trace represents the columns of a trace segment over the trace domain
+type Trace struct {
+	Columns [][]ff.FieldElement
+}
+
+// NewTrace creates a trace from columns of the same length, a power of 2
+func NewTrace(columns ...[]ff.FieldElement) *Trace {
+
+	if len(columns) == 0 {
+		panic("trace must have at least one column")
+	}
+	n := len(columns[0])
+	if n < 2 || n&(n-1) != 0 {
+		panic("trace length must be a power of 2")
+	}
+	for _, column := range columns {
+		if len(column) != n {
+			panic("trace columns must have the same length")
+		}
+	}
+	return &Trace{Columns: columns}
+}
+
+// Length returns the number of rows of the trace
+func (t *Trace) Length() int {
+	return len(t.Columns[0])
+}
+
+// Width returns the number of columns of the trace
+func (t *Trace) Width() int {
+	return len(t.Columns)
+}
+
+// Frame represents the values of the main then auxiliary columns at a point
+// x (Current) and at g.x (Next).
+type Frame struct {
+	Current []ff.FieldElement
+	Next    []ff.FieldElement
+}
+
+// BoundaryConstraint constrains a column (main columns come first then
+// auxiliary columns) to a value at a row.
+type BoundaryConstraint struct {
+	Column int
+	Row    int
+	Value  ff.FieldElement
+}
+
+// AIR represents the constraints of a computation
+type AIR interface {
+	// TraceLength returns the number of rows of the trace, a power of 2
+	TraceLength() int
+	// MainWidth returns the number of columns of the main segment
+	MainWidth() int
+	// AuxiliaryWidth returns the number of columns of the auxiliary segment,
+	// 0 if the AIR doesn't draw challenges
+	AuxiliaryWidth() int
+	// NumChallenges returns the number of challenges drawn once the main
+	// segment is committed, an AIR without challenges has no auxiliary segment
+	NumChallenges() int
+	// AuxiliaryTrace builds the auxiliary segment from the main segment and
+	// the challenges, it's only called if the AIR draws challenges
+	AuxiliaryTrace(main *Trace, challenges []ff.FieldElement) *Trace
+	// TransitionDegrees returns the degree of each transition constraint in
+	// the trace columns
+	TransitionDegrees() []int
+	// EvaluateTransitions returns the values of the transition constraints
+	// on the frame
+	EvaluateTransitions(frame Frame, challenges []ff.FieldElement) []ff.FieldElement
+	// BoundaryConstraints returns the boundary constraints, their number
+	// doesn't depend on the challenges
+	BoundaryConstraints(challenges []ff.FieldElement) []BoundaryConstraint
+}
+
+// WrappingAIR is implemented by AIRs whose last transition constraints also
+// hold between the last row and the first one.
+type WrappingAIR interface {
+	AIR
+	// NumWrappingTransitions returns the number of wrapping transitions, they
+	// come last in the transition constraints
+	NumWrappingTransitions() int
+}
+
+// numWrappingTransitions returns the number of wrapping transitions of the AIR
+func numWrappingTransitions(air AIR) int {
+	if wrapping, ok := air.(WrappingAIR); ok {
+		return wrapping.NumWrappingTransitions()
+	}
+	return 0
+}
+
+// RAPCommitments represents the commitments to the trace segments and the
+// challenges drawn in between.
+type RAPCommitments struct {
+	Main       []byte
+	Auxiliary  []byte
+	Challenges []ff.FieldElement
+}
+
+// RAPTrace represents a committed trace and the prover data needed to
+// evaluate its constraints over the evaluation domain.
+type RAPTrace struct {
+	RAPCommitments
+	Main      *Trace
+	Auxiliary *Trace
+
+	air      AIR
+	domain   []ff.FieldElement
+	g        ff.FieldElement
+	polys    []poly.Polynomial
+	evals    [][]ff.FieldElement
+	mainTree *MerkleTree
+	auxTree  *MerkleTree
+}
+
+// CommitRAPTrace commits to the main segment of the trace evaluated over the
+// domain (a coset of size a multiple of the trace length in its natural
+// order), draws the challenges from the channel and commits to the auxiliary
+// segment the AIR builds from them.
+func CommitRAPTrace(air AIR, main *Trace, domain []ff.FieldElement, channel *Channel, opts ProofOptions) *RAPTrace {
+	return commitRAPTrace(air, main, domain, channelTranscript(channel), opts)
+}
+
+// commitRAPTrace runs the trace commitment phase over a transcript
+func commitRAPTrace(air AIR, main *Trace, domain []ff.FieldElement, tr Transcript, opts ProofOptions) *RAPTrace {
+
+	n := air.TraceLength()
+	if main.Length() != n || main.Width() != air.MainWidth() {
+		panic("main trace doesn't match the AIR's trace dimensions")
+	}
+	if len(domain) < 2*n || len(domain)%n != 0 || len(domain)&(len(domain)-1) != 0 {
+		panic("evaluation domain must be a power of 2 larger than the trace")
+	}
+	rt := &RAPTrace{
+		Main:   main,
+		air:    air,
+		domain: domain,
+		g:      traceGenerator(domain, n),
+	}
+
+	rt.mainTree = rt.extend(main, opts, tr.Hasher())
+	rt.RAPCommitments.Main = rt.mainTree.Commitment()
+	proverMessage(tr, "trace.main.commitment", rt.RAPCommitments.Main)
+
+	if air.NumChallenges() > 0 {
+		rt.Challenges = drawTraceChallenges(air, tr)
+		rt.Auxiliary = air.AuxiliaryTrace(main, rt.Challenges)
+		if rt.Auxiliary == nil || rt.Auxiliary.Length() != n || rt.Auxiliary.Width() != air.AuxiliaryWidth() {
+			panic("auxiliary trace doesn't match the AIR's trace dimensions")
+		}
+		rt.auxTree = rt.extend(rt.Auxiliary, opts, tr.Hasher())
+		rt.RAPCommitments.Auxiliary = rt.auxTree.Commitment()
+		proverMessage(tr, "trace.aux.commitment", rt.RAPCommitments.Auxiliary)
+	}
+
+	return rt
+}
+
+// extend interpolates the columns of the segment over the trace domain and
+// commits to their evaluations over the evaluation domain.
+func (rt *RAPTrace) extend(segment *Trace, opts ProofOptions, hasher Hasher) *MerkleTree {
+
+	traceDomain := GenElems(rt.g, segment.Length())
+	columns := make([][]ff.FieldElement, segment.Width())
+	for i, column := range segment.Columns {
+		p := poly.Lagrange(generatePoints(traceDomain, column), PrimeField.Modulus())
+		columns[i] = make([]ff.FieldElement, len(rt.domain))
+		for k, x := range rt.domain {
+			columns[i][k] = PrimeField.NewFieldElement(p.Eval(x.Big(), PrimeField.Modulus()))
+		}
+		rt.polys = append(rt.polys, p)
+		rt.evals = append(rt.evals, columns[i])
+	}
+	return commitBatch(columns, opts.FoldingFactor, opts.CapHeight, hasher)
+}
+
+// ReadRAPCommitments reads the commitments to the trace segments and draws
+// the challenges the same way CommitRAPTrace does.
+func ReadRAPCommitments(air AIR, tr Transcript) (*RAPCommitments, error) {
+
+	main, err := tr.Message("trace.main.commitment", nil)
+	if err != nil {
+		return nil, err
+	}
+	commitments := &RAPCommitments{Main: main}
+	if air.NumChallenges() > 0 {
+		commitments.Challenges = drawTraceChallenges(air, tr)
+		commitments.Auxiliary, err = tr.Message("trace.aux.commitment", nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return commitments, nil
+}
+
+// Equal returns true if both commit to the same segments and challenges
+func (c *RAPCommitments) Equal(other *RAPCommitments) bool {
+
+	if !bytes.Equal(c.Main, other.Main) || !bytes.Equal(c.Auxiliary, other.Auxiliary) {
+		return false
+	}
+	if len(c.Challenges) != len(other.Challenges) {
+		return false
+	}
+	for i := range c.Challenges {
+		if !c.Challenges[i].Equal(other.Challenges[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// drawTraceChallenges draws the challenges the auxiliary segment depends on
+func drawTraceChallenges(air AIR, tr Transcript) []ff.FieldElement {
+
+	randomFEs := tr.RandFEs("trace.challenge", air.NumChallenges(), PrimeField.Modulus())
+	challenges := make([]ff.FieldElement, len(randomFEs))
+	for i, randomFE := range randomFEs {
+		challenges[i] = PrimeField.NewFieldElement(randomFE)
+	}
+	return challenges
+}
+
+// traceGenerator returns the generator of the trace domain given the
+// evaluation domain c.<h> where g = h^(|domain| / n)
+func traceGenerator(domain []ff.FieldElement, n int) ff.FieldElement {
+	return PrimeField.Div(domain[len(domain)/n], domain[0])
+}
+
+// Frame returns the frame of the trace at x
+func (rt *RAPTrace) Frame(x ff.FieldElement) Frame {
+
+	gx := PrimeField.Mul(rt.g, x)
+	frame := Frame{
+		Current: make([]ff.FieldElement, len(rt.polys)),
+		Next:    make([]ff.FieldElement, len(rt.polys)),
+	}
+	for i, p := range rt.polys {
+		frame.Current[i] = PrimeField.NewFieldElement(p.Eval(x.Big(), PrimeField.Modulus()))
+		frame.Next[i] = PrimeField.NewFieldElement(p.Eval(gx.Big(), PrimeField.Modulus()))
+	}
+	return frame
+}
+
+// QuotientDegreeBounds returns the degree bounds of the transition then
+// boundary quotients of the AIR.
+func QuotientDegreeBounds(air AIR, challenges []ff.FieldElement) []int {
+
+	n := air.TraceLength()
+	degrees := air.TransitionDegrees()
+	wrapping := len(degrees) - numWrappingTransitions(air)
+	var bounds []int
+	for i, degree := range degrees {
+		if i < wrapping {
+			bounds = append(bounds, QuotientDegreeBound(degree, n, n-1))
+		} else {
+			bounds = append(bounds, QuotientDegreeBound(degree, n, n))
+		}
+	}
+	for range air.BoundaryConstraints(challenges) {
+		bounds = append(bounds, QuotientDegreeBound(1, n, 1))
+	}
+	return bounds
+}
+
+// EvaluateQuotients returns the values at x of the transition then boundary
+// quotients given the frame of the trace at x, g is the trace domain's
+// generator.
+func EvaluateQuotients(air AIR, frame Frame, challenges []ff.FieldElement, x ff.FieldElement, g ff.FieldElement) ([]ff.FieldElement, error) {
+
+	n := air.TraceLength()
+	degrees := air.TransitionDegrees()
+	transitions := air.EvaluateTransitions(frame, challenges)
+	if len(transitions) != len(degrees) {
+		return nil, fmt.Errorf("AIR evaluates %d transitions but declares %d degrees", len(transitions), len(degrees))
+	}
+
+	// (X^n - 1) / (X - g^(n-1)) vanishes on every row but the last one
+	vanishing := PrimeField.Sub(x.Exp(big.NewInt(int64(n))), PrimeField.One())
+	last := PrimeField.Sub(x, g.Exp(big.NewInt(int64(n-1))))
+	if vanishing.Equal(PrimeField.Zero()) {
+		return nil, errors.New("quotients can't be evaluated on the trace domain")
+	}
+	transitionZerofier := PrimeField.Div(vanishing, last)
+
+	wrapping := len(transitions) - numWrappingTransitions(air)
+	if wrapping < 0 {
+		return nil, errors.New("AIR declares more wrapping transitions than transitions")
+	}
+	quotients := make([]ff.FieldElement, 0, len(transitions))
+	for i, value := range transitions {
+		if i < wrapping {
+			quotients = append(quotients, PrimeField.Div(value, transitionZerofier))
+		} else {
+			quotients = append(quotients, PrimeField.Div(value, vanishing))
+		}
+	}
+	for _, constraint := range air.BoundaryConstraints(challenges) {
+		if constraint.Column < 0 || constraint.Column >= len(frame.Current) {
+			return nil, fmt.Errorf("boundary constraint on missing column %d", constraint.Column)
+		}
+		numerator := PrimeField.Sub(frame.Current[constraint.Column], constraint.Value)
+		zerofier := PrimeField.Sub(x, g.Exp(big.NewInt(int64(constraint.Row))))
+		quotients = append(quotients, PrimeField.Div(numerator, zerofier))
+	}
+	return quotients, nil
+}
+
+// CompositionEvaluations returns the evaluations over the domain of the
+// degree adjusted composition of the quotients given the coefficients
+// alpha_0, beta_0, alpha_1...
+func (rt *RAPTrace) CompositionEvaluations(coefficients []ff.FieldElement) []ff.FieldElement {
+
+	bounds := QuotientDegreeBounds(rt.air, rt.Challenges)
+	if CompositionDegreeBound(bounds) >= len(rt.domain) {
+		panic("evaluation domain is too small for the composition")
+	}
+	step := len(rt.domain) / rt.air.TraceLength()
+	evals := make([]ff.FieldElement, len(rt.domain))
+	for k, x := range rt.domain {
+		// g.x is the point step positions further in the domain
+		frame := Frame{
+			Current: make([]ff.FieldElement, len(rt.evals)),
+			Next:    make([]ff.FieldElement, len(rt.evals)),
+		}
+		for i, column := range rt.evals {
+			frame.Current[i] = column[k]
+			frame.Next[i] = column[(k+step)%len(column)]
+		}
+		quotients, err := EvaluateQuotients(rt.air, frame, rt.Challenges, x, rt.g)
+		if err != nil {
+			panic(err)
+		}
+		evals[k] = DegreeAdjustedEvaluation(x, quotients, bounds, coefficients)
+	}
+	return evals
+}
+
+// A RAP proof shows the committed trace satisfies the AIR (DEEP-ALI) :
+// - the segments are committed and the coefficients of the composition H
+// of the quotients are drawn, the prover commits to the evaluations of H
+// - an out of domain point z is drawn and the prover sends the frame of the
+// trace at z and g.z, the verifier evaluates the quotients on the frame and
+// computes H(z) = DegreeAdjustedEvaluation(z, EvaluateQuotients(frame))
+// - alpha is drawn and FRI runs on the DEEP combination of the columns c_i
+// and H with the powers of alpha
+// (c_0(X) - c_0(z)) / (X - z), (c_0(X) - c_0(g.z)) / (X - g.z), (c_1(X) -
+// c_1(z)) / (X - z)... and finally (H(X) - H(z)) / (X - z)
+// which is of low degree only if the frame and H(z) are the values of the
+// committed polynomials, i.e only if H matches the trace at z.
+// - at each query the segments and H are opened and the verifier checks the
+// combination against the first FRI layer.
+
+// ProveRAP commits to the trace (the main segment and the auxiliary segment
+// the AIR builds from it) and proves it satisfies the AIR's constraints, the
+// proof is recorded by the transcript.
+func ProveRAP(air AIR, main *Trace, domain []ff.FieldElement, tr Transcript, opts ProofOptions) *RAPCommitments {
+
+	rt := commitRAPTrace(air, main, domain, tr, opts)
+	coefficients := drawCompositionCoefficients(air, rt.Challenges, tr)
+	rt.proveComposition(rt.CompositionEvaluations(coefficients), coefficients, tr, opts)
+
+	return &rt.RAPCommitments
+}
+
+// VerifyRAP verifies a proof produced by ProveRAP that the committed trace
+// satisfies the AIR's constraints and returns its commitments.
+func VerifyRAP(air AIR, domain []ff.FieldElement, tr Transcript, opts ProofOptions) (*RAPCommitments, error) {
+
+	n := air.TraceLength()
+	size := len(domain)
+	factor := opts.FoldingFactor
+	if n < 2 || n&(n-1) != 0 || size < 2*n || size&(size-1) != 0 {
+		return nil, errors.New("evaluation domain must be a power of 2 larger than the trace")
+	}
+	if factor < 2 || factor&(factor-1) != 0 {
+		return nil, errors.New("folding factor must be a power of 2")
+	}
+	mainWidth, auxWidth := air.MainWidth(), air.AuxiliaryWidth()
+	if air.NumChallenges() == 0 {
+		auxWidth = 0
+	}
+
+	commitments, err := ReadRAPCommitments(air, tr)
+	if err != nil {
+		return nil, err
+	}
+	bounds := QuotientDegreeBounds(air, commitments.Challenges)
+	target := CompositionDegreeBound(bounds)
+	if target >= size {
+		return nil, errors.New("evaluation domain is too small for the composition")
+	}
+	coefficients := drawCompositionCoefficients(air, commitments.Challenges, tr)
+	compositionCommitment, err := tr.Message("composition.commitment", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	z := PrimeField.NewFieldElement(tr.RandFE("trace.ood", PrimeField.Modulus()))
+	b, err := tr.Message("trace.ood.frame", nil)
+	if err != nil {
+		return nil, err
+	}
+	values, err := decodeElements(tr.Hasher(), b)
+	if err != nil || len(values) != 2*(mainWidth+auxWidth) {
+		return nil, errors.New("bad out of domain frame")
+	}
+	frame := Frame{Current: values[:mainWidth+auxWidth], Next: values[mainWidth+auxWidth:]}
+	g := traceGenerator(domain, n)
+	quotients, err := EvaluateQuotients(air, frame, commitments.Challenges, z, g)
+	if err != nil {
+		return nil, err
+	}
+	value := DegreeAdjustedEvaluation(z, quotients, bounds, coefficients)
+	alpha := PrimeField.NewFieldElement(tr.RandFE("trace.deep.alpha", PrimeField.Modulus()))
+
+	deepCommitment, err := tr.Message("fri.evaluations", nil)
+	if err != nil {
+		return nil, err
+	}
+	verifier := NewFRIVerifier(domain, target-1, tr.Hasher(), opts)
+	if err := verifier.ReadCommitments(tr, deepCommitment); err != nil {
+		return nil, err
+	}
+	indices, err := readFRIQueries(tr, size, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifier.VerifyQueries(tr, indices); err != nil {
+		return nil, err
+	}
+
+	leaves := queriedLeaves(indices, size, factor)
+	mainColumns, err := readColumns(tr, commitments.Main, mainWidth, leaves, size, opts, "trace.main")
+	if err != nil {
+		return nil, err
+	}
+	var auxColumns [][]ff.FieldElement
+	if auxWidth > 0 {
+		if auxColumns, err = readColumns(tr, commitments.Auxiliary, auxWidth, leaves, size, opts, "trace.aux"); err != nil {
+			return nil, err
+		}
+	}
+	compositionColumns, err := readColumns(tr, compositionCommitment, 1, leaves, size, opts, "composition")
+	if err != nil {
+		return nil, err
+	}
+
+	gz := PrimeField.Mul(g, z)
+	cosets := verifier.FirstLayerCosets()
+	row := make([]ff.FieldElement, mainWidth+auxWidth)
+	for q, leaf := range leaves {
+		for t := 0; t < factor; t++ {
+			x := domain[bitReverseIndex(leaf*factor+t, log2(size))]
+			for i := 0; i < mainWidth; i++ {
+				row[i] = mainColumns[q][i*factor+t]
+			}
+			for i := 0; i < auxWidth; i++ {
+				row[mainWidth+i] = auxColumns[q][i*factor+t]
+			}
+			deep, err := deepRAPCombination(x, z, gz, row, frame, compositionColumns[q][t], value, alpha)
+			if err != nil {
+				return nil, err
+			}
+			if !deep.Equal(cosets[q][t]) {
+				return nil, fmt.Errorf("trace doesn't match the FRI layer at query %d", q)
+			}
+		}
+	}
+
+	return commitments, nil
+}
+
+// drawCompositionCoefficients draws the coefficients alpha_0, beta_0,
+// alpha_1... of the composition of the AIR's quotients
+func drawCompositionCoefficients(air AIR, challenges []ff.FieldElement, tr Transcript) []ff.FieldElement {
+
+	bounds := QuotientDegreeBounds(air, challenges)
+	randomFEs := tr.RandFEs("composition.coefficient", 2*len(bounds), PrimeField.Modulus())
+	coefficients := make([]ff.FieldElement, len(randomFEs))
+	for i, randomFE := range randomFEs {
+		coefficients[i] = PrimeField.NewFieldElement(randomFE)
+	}
+	return coefficients
+}
+
+// proveComposition commits to the evaluations of the composition, sends the
+// out of domain frame and proves the DEEP combination is of low degree.
+func (rt *RAPTrace) proveComposition(composition []ff.FieldElement, coefficients []ff.FieldElement, tr Transcript, opts ProofOptions) {
+
+	bounds := QuotientDegreeBounds(rt.air, rt.Challenges)
+	factor := opts.FoldingFactor
+	compositionTree := commitBatch([][]ff.FieldElement{composition}, factor, opts.CapHeight, tr.Hasher())
+	proverMessage(tr, "composition.commitment", compositionTree.Commitment())
+
+	z := PrimeField.NewFieldElement(tr.RandFE("trace.ood", PrimeField.Modulus()))
+	frame := rt.Frame(z)
+	quotients, err := EvaluateQuotients(rt.air, frame, rt.Challenges, z, rt.g)
+	if err != nil {
+		panic(err)
+	}
+	value := DegreeAdjustedEvaluation(z, quotients, bounds, coefficients)
+	values := append(append([]ff.FieldElement(nil), frame.Current...), frame.Next...)
+	proverMessage(tr, "trace.ood.frame", encodeElements(tr.Hasher(), values))
+	alpha := PrimeField.NewFieldElement(tr.RandFE("trace.deep.alpha", PrimeField.Modulus()))
+
+	gz := PrimeField.Mul(rt.g, z)
+	deep := make([]ff.FieldElement, len(rt.domain))
+	row := make([]ff.FieldElement, len(rt.evals))
+	for k, x := range rt.domain {
+		for i, column := range rt.evals {
+			row[i] = column[k]
+		}
+		if deep[k], err = deepRAPCombination(x, z, gz, row, frame, composition[k], value, alpha); err != nil {
+			panic(err)
+		}
+	}
+
+	_, friTrees := friCommit(deep, rt.domain, CompositionDegreeBound(bounds)-1, tr, opts)
+	indices := friQueries(tr, len(rt.domain), opts)
+	decommitFRILayers(indices, tr, friTrees, factor)
+
+	leaves := queriedLeaves(indices, len(rt.domain), factor)
+	openLeaves(tr, rt.mainTree, leaves, "trace.main.opening", "trace.main.multiproof")
+	if rt.auxTree != nil {
+		openLeaves(tr, rt.auxTree, leaves, "trace.aux.opening", "trace.aux.multiproof")
+	}
+	openLeaves(tr, compositionTree, leaves, "composition.opening", "composition.multiproof")
+}
+
+// deepRAPCombination returns the DEEP combination at x given the values of
+// the columns and of the composition at x, the frame at z and H(z).
+func deepRAPCombination(x, z, gz ff.FieldElement, row []ff.FieldElement, frame Frame, composition, value ff.FieldElement, alpha ff.FieldElement) (ff.FieldElement, error) {
+
+	if x.Equal(z) || x.Equal(gz) {
+		return x, errors.New("out of domain point lies in the domain")
+	}
+	invZ := PrimeField.Sub(x, z).Inv()
+	invGZ := PrimeField.Sub(x, gz).Inv()
+
+	result := PrimeField.Mul(PrimeField.Sub(composition, value), invZ)
+	for i := len(row) - 1; i >= 0; i-- {
+		result = PrimeField.Add(PrimeField.Mul(result, alpha), PrimeField.Mul(PrimeField.Sub(row[i], frame.Next[i]), invGZ))
+		result = PrimeField.Add(PrimeField.Mul(result, alpha), PrimeField.Mul(PrimeField.Sub(row[i], frame.Current[i]), invZ))
+	}
+	return result, nil
+}
+
+// readColumns reads the leaves opened by openLeaves in a tree committed to
+// numColumns columns with commitBatch and decodes their elements, the t-th
+// element of the block of column i is at i * factor + t.
+func readColumns(tr Transcript, commitment []byte, numColumns int, leaves []int, size int, opts ProofOptions, label string) ([][]ff.FieldElement, error) {
+
+	factor := opts.FoldingFactor
+	opened, err := readLeaves(tr, commitment, size/factor, opts.CapHeight, leaves, label+".opening", label+".multiproof")
+	if err != nil {
+		return nil, err
+	}
+	columns := make([][]ff.FieldElement, len(opened))
+	for q, leaf := range opened {
+		columns[q], err = decodeElements(tr.Hasher(), leaf)
+		if err != nil || len(columns[q]) != numColumns*factor {
+			return nil, fmt.Errorf("bad %s opening at query %d", label, q)
+		}
+	}
+	return columns, nil
+}
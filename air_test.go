@@ -0,0 +1,242 @@
+package zkstarks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"github.com/stretchr/testify/assert"
+)
+
+// fibonacciAIR constrains the columns a, b to a' = b and b' = a + b, with an
+// auxiliary segment it also accumulates z' = z.(gamma - a) from z = 1, a
+// cheating prover builds z with gamma + 1.
+type fibonacciAIR struct {
+	n         int
+	auxiliary bool
+	cheat     bool
+}
+
+func (air fibonacciAIR) TraceLength() int {
+	return air.n
+}
+
+func (air fibonacciAIR) MainWidth() int {
+	return 2
+}
+
+func (air fibonacciAIR) AuxiliaryWidth() int {
+	return air.NumChallenges()
+}
+
+func (air fibonacciAIR) NumChallenges() int {
+	if air.auxiliary {
+		return 1
+	}
+	return 0
+}
+
+func (air fibonacciAIR) AuxiliaryTrace(main *Trace, challenges []ff.FieldElement) *Trace {
+
+	gamma := challenges[0]
+	if air.cheat {
+		gamma = PrimeField.Add(gamma, PrimeField.One())
+	}
+	z := make([]ff.FieldElement, air.n)
+	z[0] = PrimeField.One()
+	for i := 1; i < air.n; i++ {
+		z[i] = PrimeField.Mul(z[i-1], PrimeField.Sub(gamma, main.Columns[0][i-1]))
+	}
+	return NewTrace(z)
+}
+
+func (air fibonacciAIR) TransitionDegrees() []int {
+	if air.auxiliary {
+		return []int{1, 1, 2}
+	}
+	return []int{1, 1}
+}
+
+func (air fibonacciAIR) EvaluateTransitions(frame Frame, challenges []ff.FieldElement) []ff.FieldElement {
+
+	a, b := frame.Current[0], frame.Current[1]
+	transitions := []ff.FieldElement{
+		PrimeField.Sub(frame.Next[0], b),
+		PrimeField.Sub(frame.Next[1], PrimeField.Add(a, b)),
+	}
+	if air.auxiliary {
+		product := PrimeField.Mul(frame.Current[2], PrimeField.Sub(challenges[0], a))
+		transitions = append(transitions, PrimeField.Sub(frame.Next[2], product))
+	}
+	return transitions
+}
+
+func (air fibonacciAIR) BoundaryConstraints(challenges []ff.FieldElement) []BoundaryConstraint {
+
+	constraints := []BoundaryConstraint{
+		{Column: 0, Row: 0, Value: PrimeField.One()},
+		{Column: 1, Row: 0, Value: PrimeField.One()},
+	}
+	if air.auxiliary {
+		constraints = append(constraints, BoundaryConstraint{Column: 2, Row: 0, Value: PrimeField.One()})
+	}
+	return constraints
+}
+
+func fibonacciTrace(n int) *Trace {
+
+	a := make([]ff.FieldElement, n)
+	b := make([]ff.FieldElement, n)
+	a[0], b[0] = PrimeField.One(), PrimeField.One()
+	for i := 1; i < n; i++ {
+		a[i], b[i] = b[i-1], PrimeField.Add(a[i-1], b[i-1])
+	}
+	return NewTrace(a, b)
+}
+
+// proveAIR proves the trace satisfies the AIR with ProveRAP
+func proveAIR(air AIR, main *Trace, domain []ff.FieldElement, opts ProofOptions) *Proof {
+	_, proof := proveTranscript(func(tr Transcript) []byte {
+		ProveRAP(air, main, domain, tr, opts)
+		return nil
+	})
+	return proof
+}
+
+// verifyAIR verifies a proof produced by proveAIR
+func verifyAIR(air AIR, proof *Proof, domain []ff.FieldElement, opts ProofOptions) error {
+	return verifyTranscript(proof, func(tr Transcript) error {
+		_, err := VerifyRAP(air, domain, tr, opts)
+		return err
+	})
+}
+
+func TestRAP(t *testing.T) {
+
+	_, domain, _, _ := testFRIInstance(64, 0)
+	opts := DefaultProofOptions()
+	opts.NumQueries = 8
+	air := fibonacciAIR{n: 8, auxiliary: true}
+
+	t.Run("TestCommitAndRead", func(t *testing.T) {
+		for _, air := range []fibonacciAIR{air, {n: 8}} {
+			var rt *RAPTrace
+			_, proof := proveTranscript(func(tr Transcript) []byte {
+				rt = commitRAPTrace(air, fibonacciTrace(8), domain, tr, opts)
+				return nil
+			})
+			assert.Len(t, rt.Challenges, air.NumChallenges())
+			assert.Equal(t, air.auxiliary, rt.Auxiliary != nil)
+
+			err := verifyTranscript(proof, func(tr Transcript) error {
+				commitments, err := ReadRAPCommitments(air, tr)
+				if err == nil {
+					assert.True(t, commitments.Equal(&rt.RAPCommitments))
+				}
+				return err
+			})
+			assert.NoError(t, err)
+		}
+	})
+	t.Run("TestChallengesDrawnAfterMainCommitment", func(t *testing.T) {
+		channel := NewChannel()
+		rt := CommitRAPTrace(air, fibonacciTrace(8), domain, channel, opts)
+
+		labels := []string{"trace.main.commitment", "trace.challenge", "trace.aux.commitment"}
+		next := 0
+		for _, entry := range channel.Proof {
+			if next < len(labels) && strings.Contains(entry, labels[next]+":") {
+				next++
+			}
+		}
+		assert.Equal(t, len(labels), next)
+
+		// the auxiliary column depends on the challenge
+		gamma := rt.Challenges[0]
+		expected := PrimeField.Sub(gamma, rt.Main.Columns[0][0])
+		assert.True(t, rt.Auxiliary.Columns[0][1].Equal(expected))
+	})
+	t.Run("TestQuotientsSpanBothSegments", func(t *testing.T) {
+		tr := NewProverTranscript(NewSHA3Hasher())
+		rt := commitRAPTrace(air, fibonacciTrace(8), domain, tr, opts)
+		bounds := QuotientDegreeBounds(air, rt.Challenges)
+		assert.Equal(t, []int{1, 1, 8, 7, 7, 7}, bounds)
+
+		coefficients := make([]ff.FieldElement, 2*len(bounds))
+		for i := range coefficients {
+			coefficients[i] = PrimeField.NewFieldElementFromInt64(int64(31*i + 7))
+		}
+		evals := rt.CompositionEvaluations(coefficients)
+		g := traceGenerator(domain, 8)
+		for _, k := range []int{0, 5, 42} {
+			quotients, err := EvaluateQuotients(air, rt.Frame(domain[k]), rt.Challenges, domain[k], g)
+			assert.NoError(t, err)
+			assert.True(t, DegreeAdjustedEvaluation(domain[k], quotients, bounds, coefficients).Equal(evals[k]))
+		}
+
+		_, err := EvaluateQuotients(air, rt.Frame(g), rt.Challenges, g, g)
+		assert.Error(t, err)
+	})
+	t.Run("TestProveAndVerify", func(t *testing.T) {
+		for _, air := range []fibonacciAIR{air, {n: 8}} {
+			for _, factor := range []int{2, 4} {
+				opts := opts
+				opts.FoldingFactor = factor
+				proof := proveAIR(air, fibonacciTrace(8), domain, opts)
+				assert.NoError(t, verifyAIR(air, proof, domain, opts), "factor %d", factor)
+			}
+		}
+	})
+	t.Run("TestRejectInvalidTrace", func(t *testing.T) {
+		main := fibonacciTrace(8)
+		main.Columns[1][5] = PrimeField.Add(main.Columns[1][5], PrimeField.One())
+		assert.Error(t, verifyAIR(air, proveAIR(air, main, domain, opts), domain, opts))
+	})
+	t.Run("TestRejectTamperedProof", func(t *testing.T) {
+		proof := proveAIR(air, fibonacciTrace(8), domain, opts)
+		assertRejectsTamperedMessages(t, proof, func(proof *Proof) error {
+			return verifyAIR(air, proof, domain, opts)
+		}, "trace.main.commitment", "trace.aux.commitment", "composition.commitment", "trace.ood.frame",
+			"trace.main.opening", "trace.aux.opening", "composition.opening")
+	})
+	t.Run("TestRejectMaliciousProver", func(t *testing.T) {
+		// the prover builds the auxiliary column with gamma + 1 and commits
+		// to the composition for that challenge, which is of low degree
+		var composition []ff.FieldElement
+		var bound int
+		_, proof := proveTranscript(func(tr Transcript) []byte {
+			rt := commitRAPTrace(fibonacciAIR{n: 8, auxiliary: true, cheat: true}, fibonacciTrace(8), domain, tr, opts)
+			coefficients := drawCompositionCoefficients(air, rt.Challenges, tr)
+			rt.Challenges = []ff.FieldElement{PrimeField.Add(rt.Challenges[0], PrimeField.One())}
+			composition = rt.CompositionEvaluations(coefficients)
+			bound = CompositionDegreeBound(QuotientDegreeBounds(air, rt.Challenges))
+			rt.proveComposition(composition, coefficients, tr, opts)
+			return nil
+		})
+		assert.Error(t, verifyAIR(air, proof, domain, opts))
+
+		// a low degree test of the composition alone accepts it
+		commitment, friProof := proveTranscript(func(tr Transcript) []byte {
+			return FRIProve(composition, domain, bound, tr, opts)
+		})
+		assert.NoError(t, verifyTranscript(friProof, func(tr Transcript) error {
+			return FRIVerify(commitment, domain, bound, tr, opts)
+		}))
+
+		// a prover committing to an invalid trace and to a composition of
+		// low degree can't answer for it at the out of domain point
+		main := fibonacciTrace(8)
+		main.Columns[0][3] = PrimeField.Zero()
+		_, proof = proveTranscript(func(tr Transcript) []byte {
+			rt := commitRAPTrace(air, main, domain, tr, opts)
+			coefficients := drawCompositionCoefficients(air, rt.Challenges, tr)
+			zeros := make([]ff.FieldElement, len(domain))
+			for k := range zeros {
+				zeros[k] = PrimeField.Zero()
+			}
+			rt.proveComposition(zeros, coefficients, tr, opts)
+			return nil
+		})
+		assert.Error(t, verifyAIR(air, proof, domain, opts))
+	})
+}
@@ -0,0 +1,147 @@
+package zkstarks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/actuallyachraf/algebra/ff"
+)
+
+// Batched FRI tests several columns of evaluations over the same domain
+// with a single FRI instance :
+// - the prover commits to every column in a single tree, the leaf j holds
+// the j-th bit-reversed block of each column so a query opens one leaf
+// - a challenge alpha is drawn and FRI runs on sum_i alpha^i f_i
+// - at each query the verifier recombines the opened columns and checks the
+// combination against the block opened in the first FRI layer.
+// If one column is far from the polynomials of the degree bound so is the
+// combination except for few alphas, the proof holds one FRI instance and a
+// batch leaf per query whatever the number of columns.
+
+// BatchFRIProve proves that each column of evaluations over the domain (given
+// in its natural order) is of a polynomial with less than degreeBound
+// coefficients and returns the commitment to the columns.
+func BatchFRIProve(columns [][]ff.FieldElement, domain []ff.FieldElement, degreeBound int, tr Transcript, opts ProofOptions) []byte {
+
+	if len(columns) == 0 {
+		panic("no columns to batch")
+	}
+	if len(domain) == 0 || len(domain)&(len(domain)-1) != 0 {
+		panic("evaluations must be given over a domain of size a power of 2")
+	}
+	for _, column := range columns {
+		if len(column) != len(domain) {
+			panic("columns must be evaluated over the domain")
+		}
+	}
+	factor := opts.FoldingFactor
+
+	batchTree := commitBatch(columns, factor, opts.CapHeight, tr.Hasher())
+	commitment := batchTree.Commitment()
+	proverMessage(tr, "fri.batch.commitment", commitment)
+
+	alpha := PrimeField.NewFieldElement(tr.RandFE("fri.batch.alpha", PrimeField.Modulus()))
+	combination := make([]ff.FieldElement, len(domain))
+	for k := range combination {
+		combination[k] = PrimeField.Zero()
+	}
+	coeff := PrimeField.One()
+	for _, column := range columns {
+		for k, eval := range column {
+			combination[k] = PrimeField.Add(combination[k], PrimeField.Mul(coeff, eval))
+		}
+		coeff = PrimeField.Mul(coeff, alpha)
+	}
+
+	_, friTrees := friCommit(combination, domain, degreeBound, tr, opts)
+	indices := friQueries(tr, len(domain), opts)
+	decommitFRILayers(indices, tr, friTrees, factor)
+
+	openLeaves(tr, batchTree, queriedLeaves(indices, len(domain), factor), "fri.batch.opening", "fri.batch.multiproof")
+
+	return commitment
+}
+
+// BatchFRIVerify verifies a proof produced by BatchFRIProve that each of the
+// numColumns columns committed to by the commitment is of a polynomial with
+// less than degreeBound coefficients.
+func BatchFRIVerify(commitment []byte, numColumns int, domain []ff.FieldElement, degreeBound int, tr Transcript, opts ProofOptions) error {
+
+	if numColumns < 1 {
+		return errors.New("no columns to batch")
+	}
+	if len(domain) == 0 || len(domain)&(len(domain)-1) != 0 {
+		return errors.New("domain size must be a power of 2")
+	}
+	factor := opts.FoldingFactor
+
+	sent, err := tr.Message("fri.batch.commitment", nil)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(sent, commitment) {
+		return errors.New("proof doesn't commit to the given columns")
+	}
+	alpha := PrimeField.NewFieldElement(tr.RandFE("fri.batch.alpha", PrimeField.Modulus()))
+
+	combinationCommitment, err := tr.Message("fri.evaluations", nil)
+	if err != nil {
+		return err
+	}
+	verifier := NewFRIVerifier(domain, degreeBound, tr.Hasher(), opts)
+	if err := verifier.ReadCommitments(tr, combinationCommitment); err != nil {
+		return err
+	}
+	indices, err := readFRIQueries(tr, len(domain), opts)
+	if err != nil {
+		return err
+	}
+	if err := verifier.VerifyQueries(tr, indices); err != nil {
+		return err
+	}
+
+	leaves, err := readLeaves(tr, commitment, len(domain)/factor, opts.CapHeight, queriedLeaves(indices, len(domain), factor), "fri.batch.opening", "fri.batch.multiproof")
+	if err != nil {
+		return err
+	}
+	cosets := verifier.FirstLayerCosets()
+	for q, leaf := range leaves {
+		values, err := decodeElements(tr.Hasher(), leaf)
+		if err != nil || len(values) != numColumns*factor {
+			return fmt.Errorf("bad batch opening at query %d", q)
+		}
+
+		for t := 0; t < factor; t++ {
+			combination := PrimeField.Zero()
+			coeff := PrimeField.One()
+			for i := 0; i < numColumns; i++ {
+				combination = PrimeField.Add(combination, PrimeField.Mul(coeff, values[i*factor+t]))
+				coeff = PrimeField.Mul(coeff, alpha)
+			}
+			if !combination.Equal(cosets[q][t]) {
+				return fmt.Errorf("batch opening doesn't match the combination at query %d", q)
+			}
+		}
+	}
+
+	return nil
+}
+
+// commitBatch commits to the columns, the leaf j holds the j-th block of
+// each bit-reversed column.
+func commitBatch(columns [][]ff.FieldElement, factor int, capHeight int, hasher Hasher) *MerkleTree {
+
+	reversed := make([][]ff.FieldElement, len(columns))
+	for i, column := range columns {
+		reversed[i] = BitReverse(column)
+	}
+
+	leaves := make([][]byte, len(columns[0])/factor)
+	for j := range leaves {
+		for _, column := range reversed {
+			leaves[j] = append(leaves[j], encodeElements(hasher, column[j*factor:(j+1)*factor])...)
+		}
+	}
+	return NewMerkleTreeWithHasher(leaves, capHeight, hasher)
+}
@@ -0,0 +1,73 @@
+package zkstarks
+
+import (
+	"testing"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchFRI(t *testing.T) {
+
+	// testColumns evaluates a polynomial with the given number of
+	// coefficients per column on the test domain
+	testColumns := func(degrees ...int) ([]ff.FieldElement, [][]ff.FieldElement) {
+		var domain []ff.FieldElement
+		columns := make([][]ff.FieldElement, len(degrees))
+		for i, degree := range degrees {
+			coeffs := make([]int, degree)
+			for k := range coeffs {
+				coeffs[k] = 3*i + k + 1
+			}
+			_, domain, columns[i], _ = testFRIInstance(64, coeffs...)
+		}
+		return domain, columns
+	}
+	prove := func(opts ProofOptions, degrees ...int) ([]byte, *Proof) {
+		domain, columns := testColumns(degrees...)
+		return proveTranscript(func(tr Transcript) []byte {
+			return BatchFRIProve(columns, domain, 16, tr, opts)
+		})
+	}
+	verify := func(commitment []byte, proof *Proof, numColumns int, opts ProofOptions) error {
+		_, domain, _, _ := testFRIInstance(64, 0)
+		return verifyTranscript(proof, func(tr Transcript) error {
+			return BatchFRIVerify(commitment, numColumns, domain, 16, tr, opts)
+		})
+	}
+
+	t.Run("TestProveAndVerify", func(t *testing.T) {
+		for _, factor := range []int{2, 4} {
+			opts := DefaultProofOptions()
+			opts.FoldingFactor, opts.NumQueries = factor, 8
+			commitment, proof := prove(opts, 16, 12, 5, 1)
+			assert.NoError(t, verify(commitment, proof, 4, opts), "factor %d", factor)
+		}
+	})
+	t.Run("TestProofSizeIsFlat", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		_, one := prove(opts, 16)
+		_, many := prove(opts, 16, 16, 16, 16, 16, 16, 16, 16)
+		assert.Equal(t, len(one.Messages), len(many.Messages))
+	})
+	t.Run("TestRejectHighDegreeColumn", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.NumQueries = 8
+		commitment, proof := prove(opts, 16, 17, 4)
+		assert.Error(t, verify(commitment, proof, 3, opts))
+	})
+	t.Run("TestRejectWrongNumberOfColumns", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		commitment, proof := prove(opts, 16, 8)
+		assert.NoError(t, verify(commitment, proof, 2, opts))
+		assert.Error(t, verify(commitment, proof, 1, opts))
+		assert.Error(t, verify(commitment, proof, 3, opts))
+	})
+	t.Run("TestRejectTamperedOpening", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		commitment, proof := prove(opts, 16, 8)
+		assertRejectsTamperedMessages(t, proof, func(proof *Proof) error {
+			return verify(commitment, proof, 2, opts)
+		}, "fri.batch.opening")
+	})
+}
@@ -1,5 +1,55 @@
 package main
 
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/actuallyachraf/zkstarks"
+)
+
+const usage = `usage : zkstarks diff <trace-a.json> <trace-b.json>
+
+diff compares two channel traces recorded in debug mode (JSON arrays of
+{operation,label,payload,state}) and reports the first differing operation.`
+
 func main() {
 
+	if len(os.Args) != 4 || os.Args[1] != "diff" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	a, err := readTrace(os.Args[2])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	b, err := readTrace(os.Args[3])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	diff := zkstarks.DiffTraces(a, b)
+	if diff == nil {
+		fmt.Println("transcripts are identical")
+		return
+	}
+	fmt.Println(diff)
+	os.Exit(1)
+}
+
+func readTrace(path string) ([]zkstarks.TraceEntry, error) {
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var trace []zkstarks.TraceEntry
+	if err := json.Unmarshal(b, &trace); err != nil {
+		return nil, fmt.Errorf("failed to parse trace %s : %v", path, err)
+	}
+	return trace, nil
 }
@@ -0,0 +1,76 @@
+package zkstarks
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/actuallyachraf/algebra/ff"
+)
+
+// serializeFieldElements encodes field elements as fixed size big endian
+// integers.
+func serializeFieldElements(elems []ff.FieldElement) []byte {
+
+	if len(elems) == 0 {
+		return []byte{}
+	}
+	size := (elems[0].Field().Modulus().BitLen() + 7) / 8
+	b := make([]byte, 0, size*len(elems))
+
+	for _, elem := range elems {
+		buf := make([]byte, size)
+		n := elem.Big().Bytes()
+		copy(buf[size-len(n):], n)
+		b = append(b, buf...)
+	}
+	return b
+}
+
+// deserializeFieldElements parses elements serialized by serializeFieldElements
+func deserializeFieldElements(field ff.FiniteField, b []byte) ([]ff.FieldElement, error) {
+
+	size := (field.Modulus().BitLen() + 7) / 8
+	if len(b)%size != 0 {
+		return nil, errors.New("bad field elements encoding")
+	}
+	elems := make([]ff.FieldElement, len(b)/size)
+	for i := range elems {
+		x := new(big.Int).SetBytes(b[i*size : (i+1)*size])
+		if x.Cmp(field.Modulus()) >= 0 {
+			return nil, errors.New("bad field elements encoding")
+		}
+		elems[i] = field.NewFieldElement(x)
+	}
+	return elems, nil
+}
+
+// encodeElements encodes the field elements of a message or a merkle leaf,
+// transcripts and trees hashed with Keccak-256 use uint256 words so that an
+// EVM verifier reads them and recomputes the hashes with abi.encodePacked.
+func encodeElements(hasher Hasher, elems []ff.FieldElement) []byte {
+	if hasher != nil && hasher.Name() == keccakName {
+		return bytes.Join(DomainWords(elems), nil)
+	}
+	return serializeFieldElements(elems)
+}
+
+// decodeElements parses field elements encoded by encodeElements
+func decodeElements(hasher Hasher, b []byte) ([]ff.FieldElement, error) {
+
+	if hasher.Name() != keccakName {
+		return deserializeFieldElements(PrimeField, b)
+	}
+	if len(b)%32 != 0 {
+		return nil, errors.New("bad uint256 words encoding")
+	}
+	elems := make([]ff.FieldElement, len(b)/32)
+	for i := range elems {
+		x := new(big.Int).SetBytes(b[i*32 : (i+1)*32])
+		if x.Cmp(PrimeField.Modulus()) >= 0 {
+			return nil, errors.New("bad uint256 words encoding")
+		}
+		elems[i] = PrimeField.NewFieldElement(x)
+	}
+	return elems, nil
+}
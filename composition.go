@@ -0,0 +1,309 @@
+package zkstarks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"github.com/actuallyachraf/algebra/poly"
+)
+
+// The composition polynomial H has the degree of the highest constraint
+// quotient, a multiple of the trace length n, running FRI on it directly
+// makes the FRI depth grow with the constraints' degree.
+// H is split into segments of less than n coefficients :
+// H(X) = sum_i X^(i*n) h_i(X)
+// the segments are committed as the columns of a single tree (the leaf j
+// holds the j-th bit-reversed block of each segment) and the verifier
+// recombines H from the segments wherever it needs its value.
+// The prover proves the segments are of low degree and opens them at an
+// out of domain point z with a single FRI instance :
+// - z is drawn and the prover sends the values h_i(z)
+// - alpha is drawn and FRI runs on sum_i alpha^i (h_i(X) - h_i(z)) / (X - z)
+// which has less than n - 1 coefficients
+// - at each query the verifier recomputes the combination from the opened
+// segments and checks it against the first FRI layer.
+
+// Constraint quotients q_i have different degree bounds d_i, combining them
+// with random coefficients alone only checks the combination against the
+// highest bound and a quotient of lower bound may exceed it unnoticed.
+// Each quotient is instead adjusted to the target bound D of the composition :
+// H(X) = sum_i (alpha_i + beta_i X^(D - d_i)) q_i(X)
+// so that H has less than D coefficients only if every q_i has less than d_i
+// (except for few coefficients alpha_i, beta_i).
+
+// QuotientDegreeBound returns the degree bound of the quotient of a
+// constraint of the given degree in the trace polynomial (which has less
+// than traceDegreeBound coefficients) by a zerofier of the given degree.
+func QuotientDegreeBound(constraintDegree int, traceDegreeBound int, zerofierDegree int) int {
+	return constraintDegree*(traceDegreeBound-1) - zerofierDegree + 1
+}
+
+// CompositionDegreeBound returns the target degree bound of the composition,
+// the smallest power of 2 that isn't below any of the quotients' bounds.
+func CompositionDegreeBound(degreeBounds []int) int {
+
+	target := 1
+	for _, bound := range degreeBounds {
+		for target < bound {
+			target *= 2
+		}
+	}
+	return target
+}
+
+// DegreeAdjustedComposition returns the composition of the quotients with
+// their degree bounds given the coefficients alpha_0, beta_0, alpha_1...
+// A quotient exceeding its bound isn't rejected here, its adjusted term
+// exceeds the target bound and so does the composition which the low degree
+// test at the target bound then rejects.
+func DegreeAdjustedComposition(quotients []poly.Polynomial, degreeBounds []int, coefficients []ff.FieldElement) poly.Polynomial {
+
+	if len(degreeBounds) != len(quotients) || len(coefficients) != 2*len(quotients) {
+		panic("composition needs a degree bound and two coefficients per quotient")
+	}
+	target := CompositionDegreeBound(degreeBounds)
+
+	composition := poly.NewPolynomialInts(0)
+	for i, q := range quotients {
+		adjustment := make([]ff.FieldElement, target-degreeBounds[i]+1)
+		for k := range adjustment {
+			adjustment[k] = PrimeField.Zero()
+		}
+		adjustment[0] = PrimeField.Add(adjustment[0], coefficients[2*i])
+		adjustment[len(adjustment)-1] = PrimeField.Add(adjustment[len(adjustment)-1], coefficients[2*i+1])
+
+		term := q.Mul(poly.NewPolynomial(adjustment), PrimeField.Modulus())
+		composition = composition.Add(term, PrimeField.Modulus())
+	}
+	return composition
+}
+
+// DegreeAdjustedEvaluation returns the value of the composition at x given
+// the values of the quotients at x.
+func DegreeAdjustedEvaluation(x ff.FieldElement, values []ff.FieldElement, degreeBounds []int, coefficients []ff.FieldElement) ff.FieldElement {
+
+	target := CompositionDegreeBound(degreeBounds)
+	result := PrimeField.Zero()
+	for i, value := range values {
+		shift := x.Exp(big.NewInt(int64(target - degreeBounds[i])))
+		adjustment := PrimeField.Add(coefficients[2*i], PrimeField.Mul(coefficients[2*i+1], shift))
+		result = PrimeField.Add(result, PrimeField.Mul(adjustment, value))
+	}
+	return result
+}
+
+// CompositionOpenings represents the values of the composition polynomial
+// recombined by the verifier from its segments.
+type CompositionOpenings struct {
+	// Point is the out of domain point z and Value is H(z)
+	Point ff.FieldElement
+	Value ff.FieldElement
+	// Indices are the query indices in the domain and QueryValues the
+	// values of H at the queried points
+	Indices     []int
+	QueryValues []ff.FieldElement
+}
+
+// SplitComposition splits the polynomial into segments of less than
+// segmentBound coefficients such that H(X) = sum_i X^(i*segmentBound) h_i(X)
+func SplitComposition(h poly.Polynomial, segmentBound int) []poly.Polynomial {
+
+	numSegments := (len(h) + segmentBound - 1) / segmentBound
+	if numSegments == 0 {
+		numSegments = 1
+	}
+	segments := make([]poly.Polynomial, numSegments)
+	for i := range segments {
+		coeffs := make([]ff.FieldElement, segmentBound)
+		for k := range coeffs {
+			coeffs[k] = PrimeField.Zero()
+			if i*segmentBound+k < len(h) {
+				coeffs[k] = PrimeField.NewFieldElement(h[i*segmentBound+k])
+			}
+		}
+		segments[i] = poly.NewPolynomial(coeffs)
+	}
+	return segments
+}
+
+// RecombineSegments returns H(x) given the values of the segments at x
+func RecombineSegments(x ff.FieldElement, values []ff.FieldElement, segmentBound int) ff.FieldElement {
+
+	shift := x.Exp(big.NewInt(int64(segmentBound)))
+	result := PrimeField.Zero()
+	for i := len(values) - 1; i >= 0; i-- {
+		result = PrimeField.Add(PrimeField.Mul(result, shift), values[i])
+	}
+	return result
+}
+
+// ProveComposition splits the composition polynomial into numSegments
+// segments of less than segmentBound coefficients, commits to their
+// evaluations over the domain and proves they are of low degree, it returns
+// the commitment.
+func ProveComposition(h poly.Polynomial, numSegments int, segmentBound int, domain []ff.FieldElement, tr Transcript, opts ProofOptions) []byte {
+
+	if len(domain) == 0 || len(domain)&(len(domain)-1) != 0 {
+		panic("evaluations must be given over a domain of size a power of 2")
+	}
+	segments := SplitComposition(h, segmentBound)
+	if len(segments) > numSegments {
+		panic("composition doesn't fit in the given number of segments")
+	}
+	// the verifier expects numSegments columns, a composition of lower
+	// degree is padded with zero segments
+	for len(segments) < numSegments {
+		segments = append(segments, poly.NewPolynomialInts(0))
+	}
+	columns := make([][]ff.FieldElement, len(segments))
+	for i, segment := range segments {
+		columns[i] = make([]ff.FieldElement, len(domain))
+		for k, x := range domain {
+			columns[i][k] = PrimeField.NewFieldElement(segment.Eval(x.Big(), PrimeField.Modulus()))
+		}
+	}
+	tree := commitBatch(columns, opts.FoldingFactor, opts.CapHeight, tr.Hasher())
+	commitment := tree.Commitment()
+	proverMessage(tr, "composition.segments.commitment", commitment)
+
+	z, err := drawOutOfDomainPoint(tr, "composition.ood", domain)
+	if err != nil {
+		panic(err)
+	}
+	values := make([]ff.FieldElement, len(segments))
+	for i, segment := range segments {
+		values[i] = PrimeField.NewFieldElement(segment.Eval(z.Big(), PrimeField.Modulus()))
+	}
+	proverMessage(tr, "composition.segments.ood", encodeElements(tr.Hasher(), values))
+
+	alpha := PrimeField.NewFieldElement(tr.RandFE("composition.alpha", PrimeField.Modulus()))
+	deep := make([]ff.FieldElement, len(domain))
+	for k, x := range domain {
+		column := make([]ff.FieldElement, len(columns))
+		for i := range columns {
+			column[i] = columns[i][k]
+		}
+		deep[k] = deepCombination(x, z, column, values, alpha)
+	}
+
+	_, friTrees := friCommit(deep, domain, segmentBound-1, tr, opts)
+	indices := friQueries(tr, len(domain), opts)
+	decommitFRILayers(indices, tr, friTrees, opts.FoldingFactor)
+	openLeaves(tr, tree, queriedLeaves(indices, len(domain), opts.FoldingFactor), "composition.segments.opening", "composition.segments.multiproof")
+
+	return commitment
+}
+
+// VerifyComposition verifies a proof produced by ProveComposition that the
+// commitment is to numSegments segments of less than segmentBound
+// coefficients and returns the values of the composition polynomial at the
+// out of domain point and at the queried points.
+func VerifyComposition(commitment []byte, numSegments int, segmentBound int, domain []ff.FieldElement, tr Transcript, opts ProofOptions) (*CompositionOpenings, error) {
+
+	size := len(domain)
+	factor := opts.FoldingFactor
+	if size == 0 || size&(size-1) != 0 {
+		return nil, errors.New("domain size must be a power of 2")
+	}
+
+	sent, err := tr.Message("composition.segments.commitment", nil)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(sent, commitment) {
+		return nil, errors.New("proof doesn't commit to the given segments")
+	}
+
+	z, err := drawOutOfDomainPoint(tr, "composition.ood", domain)
+	if err != nil {
+		return nil, err
+	}
+	b, err := tr.Message("composition.segments.ood", nil)
+	if err != nil {
+		return nil, err
+	}
+	values, err := decodeElements(tr.Hasher(), b)
+	if err != nil || len(values) != numSegments {
+		return nil, errors.New("bad out of domain segment values")
+	}
+	alpha := PrimeField.NewFieldElement(tr.RandFE("composition.alpha", PrimeField.Modulus()))
+
+	deepCommitment, err := tr.Message("fri.evaluations", nil)
+	if err != nil {
+		return nil, err
+	}
+	verifier := NewFRIVerifier(domain, segmentBound-1, tr.Hasher(), opts)
+	if err := verifier.ReadCommitments(tr, deepCommitment); err != nil {
+		return nil, err
+	}
+	indices, err := readFRIQueries(tr, size, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifier.VerifyQueries(tr, indices); err != nil {
+		return nil, err
+	}
+
+	leafIndices := queriedLeaves(indices, size, factor)
+	leaves, err := readLeaves(tr, commitment, size/factor, opts.CapHeight, leafIndices, "composition.segments.opening", "composition.segments.multiproof")
+	if err != nil {
+		return nil, err
+	}
+
+	openings := &CompositionOpenings{
+		Point:       z,
+		Value:       RecombineSegments(z, values, segmentBound),
+		Indices:     indices,
+		QueryValues: make([]ff.FieldElement, len(indices)),
+	}
+	cosets := verifier.FirstLayerCosets()
+	for q, leaf := range leaves {
+		evals, err := decodeElements(tr.Hasher(), leaf)
+		if err != nil || len(evals) != numSegments*factor {
+			return nil, fmt.Errorf("bad segment opening at query %d", q)
+		}
+		for t := 0; t < factor; t++ {
+			position := leafIndices[q]*factor + t
+			x := domain[bitReverseIndex(position, log2(size))]
+			column := make([]ff.FieldElement, numSegments)
+			for i := range column {
+				column[i] = evals[i*factor+t]
+			}
+			if !deepCombination(x, z, column, values, alpha).Equal(cosets[q][t]) {
+				return nil, fmt.Errorf("segments don't match the FRI layer at query %d", q)
+			}
+			if position == bitReverseIndex(indices[q]%size, log2(size)) {
+				openings.QueryValues[q] = RecombineSegments(x, column, segmentBound)
+			}
+		}
+	}
+
+	return openings, nil
+}
+
+// drawOutOfDomainPoint draws the point z and rejects it if it lies in the
+// domain where the DEEP quotients would divide by zero.
+func drawOutOfDomainPoint(tr Transcript, label string, domain []ff.FieldElement) (ff.FieldElement, error) {
+
+	z := PrimeField.NewFieldElement(tr.RandFE(label, PrimeField.Modulus()))
+	for _, x := range domain {
+		if x.Equal(z) {
+			return z, errors.New("out of domain point lies in the domain")
+		}
+	}
+	return z, nil
+}
+
+// deepCombination returns sum_i alpha^i (h_i(x) - h_i(z)) / (x - z) given
+// the values of the segments at x and z
+func deepCombination(x, z ff.FieldElement, column []ff.FieldElement, values []ff.FieldElement, alpha ff.FieldElement) ff.FieldElement {
+
+	result := PrimeField.Zero()
+	for i := len(column) - 1; i >= 0; i-- {
+		result = PrimeField.Add(PrimeField.Mul(result, alpha), PrimeField.Sub(column[i], values[i]))
+	}
+	return PrimeField.Div(result, PrimeField.Sub(x, z))
+}
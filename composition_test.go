@@ -0,0 +1,176 @@
+package zkstarks
+
+import (
+	"testing"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"github.com/actuallyachraf/algebra/poly"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComposition(t *testing.T) {
+
+	coeffs := make([]int, 40)
+	for i := range coeffs {
+		coeffs[i] = 7*i + 3
+	}
+	h := poly.NewPolynomialInts(coeffs...)
+	_, domain, _, _ := testFRIInstance(64, 0)
+
+	prove := func(opts ProofOptions, numSegments, segmentBound int) ([]byte, *Proof) {
+		return proveTranscript(func(tr Transcript) []byte {
+			return ProveComposition(h, numSegments, segmentBound, domain, tr, opts)
+		})
+	}
+	verify := func(commitment []byte, proof *Proof, numSegments, segmentBound int, opts ProofOptions) (*CompositionOpenings, error) {
+		var openings *CompositionOpenings
+		err := verifyTranscript(proof, func(tr Transcript) error {
+			var err error
+			openings, err = VerifyComposition(commitment, numSegments, segmentBound, domain, tr, opts)
+			return err
+		})
+		return openings, err
+	}
+
+	t.Run("TestSplitAndRecombine", func(t *testing.T) {
+		segments := SplitComposition(h, 16)
+		assert.Len(t, segments, 3)
+		x := PrimeField.NewFieldElementFromInt64(987654321)
+		values := make([]ff.FieldElement, len(segments))
+		for i, segment := range segments {
+			assert.True(t, len(segment) <= 16)
+			values[i] = PrimeField.NewFieldElement(segment.Eval(x.Big(), PrimeField.Modulus()))
+		}
+		expected := h.Eval(x.Big(), PrimeField.Modulus())
+		assert.Equal(t, 0, RecombineSegments(x, values, 16).Big().Cmp(expected))
+
+		assert.Len(t, SplitComposition(h, 64), 1)
+		assert.Len(t, SplitComposition(h, 8), 5)
+	})
+	t.Run("TestProveAndVerify", func(t *testing.T) {
+		for _, factor := range []int{2, 4} {
+			opts := DefaultProofOptions()
+			opts.FoldingFactor, opts.NumQueries = factor, 6
+			commitment, proof := prove(opts, 3, 16)
+			openings, err := verify(commitment, proof, 3, 16, opts)
+			assert.NoError(t, err, "factor %d", factor)
+			if err != nil {
+				continue
+			}
+
+			assert.Equal(t, 0, openings.Value.Big().Cmp(h.Eval(openings.Point.Big(), PrimeField.Modulus())))
+			assert.Len(t, openings.QueryValues, opts.NumQueries)
+			for q, index := range openings.Indices {
+				expected := h.Eval(domain[index].Big(), PrimeField.Modulus())
+				assert.Equal(t, 0, openings.QueryValues[q].Big().Cmp(expected), "query %d", q)
+			}
+		}
+	})
+	t.Run("TestRejectHighDegreeSegments", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.NumQueries = 6
+		commitment, proof := prove(opts, 3, 16)
+		_, err := verify(commitment, proof, 3, 8, opts)
+		assert.Error(t, err)
+		_, err = verify(commitment, proof, 2, 16, opts)
+		assert.Error(t, err)
+	})
+	t.Run("TestRejectTamperedProof", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		commitment, proof := prove(opts, 3, 16)
+		assertRejectsTamperedMessages(t, proof, func(proof *Proof) error {
+			_, err := verify(commitment, proof, 3, 16, opts)
+			return err
+		}, "composition.segments.commitment", "composition.segments.ood", "composition.segments.opening")
+	})
+	t.Run("TestSegmentCount", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.NumQueries = 6
+		assert.Panics(t, func() { prove(opts, 2, 16) })
+
+		// a composition of lower degree is padded to the expected count
+		commitment, proof := prove(opts, 4, 16)
+		openings, err := verify(commitment, proof, 4, 16, opts)
+		assert.NoError(t, err)
+		if err == nil {
+			assert.Equal(t, 0, openings.Value.Big().Cmp(h.Eval(openings.Point.Big(), PrimeField.Modulus())))
+		}
+	})
+	t.Run("TestRejectPointInDomain", func(t *testing.T) {
+		// a prover grinding its messages until z lands in the domain must
+		// not make either side divide by zero
+		z := PrimeField.NewFieldElement(NewProverTranscript(NewSHA3Hasher()).RandFE("composition.ood", PrimeField.Modulus()))
+		_, err := drawOutOfDomainPoint(NewProverTranscript(NewSHA3Hasher()), "composition.ood", append([]ff.FieldElement{z}, domain...))
+		assert.Error(t, err)
+		_, err = drawOutOfDomainPoint(NewProverTranscript(NewSHA3Hasher()), "composition.ood", domain)
+		assert.NoError(t, err)
+	})
+}
+
+func TestDegreeAdjustedComposition(t *testing.T) {
+
+	quotients := []poly.Polynomial{
+		poly.NewPolynomialInts(1, 2, 3, 4, 5, 6, 7, 8),
+		poly.NewPolynomialInts(9, 8, 7),
+		poly.NewPolynomialInts(1, 1, 2, 3, 5, 8, 13, 21, 34, 55, 89, 144),
+	}
+	degreeBounds := []int{8, 4, 12}
+	coefficients := make([]ff.FieldElement, 2*len(quotients))
+	for i := range coefficients {
+		coefficients[i] = PrimeField.NewFieldElementFromInt64(int64(1000*i + 17))
+	}
+
+	t.Run("TestDegreeBounds", func(t *testing.T) {
+		assert.Equal(t, 1024, QuotientDegreeBound(2, 1023, 1021))
+		assert.Equal(t, 1022, QuotientDegreeBound(1, 1023, 1))
+		assert.Equal(t, 16, CompositionDegreeBound(degreeBounds))
+		assert.Equal(t, 1024, CompositionDegreeBound([]int{1022, 1022, 1024}))
+	})
+	t.Run("TestComposition", func(t *testing.T) {
+		composition := DegreeAdjustedComposition(quotients, degreeBounds, coefficients)
+		assert.Len(t, composition, 16)
+
+		x := PrimeField.NewFieldElementFromInt64(271828)
+		values := make([]ff.FieldElement, len(quotients))
+		for i, q := range quotients {
+			values[i] = PrimeField.NewFieldElement(q.Eval(x.Big(), PrimeField.Modulus()))
+		}
+		expected := composition.Eval(x.Big(), PrimeField.Modulus())
+		assert.Equal(t, 0, DegreeAdjustedEvaluation(x, values, degreeBounds, coefficients).Big().Cmp(expected))
+	})
+	t.Run("TestRejectQuotientExceedingItsBound", func(t *testing.T) {
+		// the second quotient has 3 coefficients for a bound of 2, the
+		// composition still targets 16 coefficients but its adjusted term
+		// X^14 q_1 has 17 of them
+		cheatingBounds := []int{8, 2, 12}
+		assert.Equal(t, CompositionDegreeBound(degreeBounds), CompositionDegreeBound(cheatingBounds))
+		composition := DegreeAdjustedComposition(quotients, cheatingBounds, coefficients)
+		assert.Len(t, composition, 17)
+
+		_, domain, _, _ := testFRIInstance(64, 0)
+		evals := make([]ff.FieldElement, len(domain))
+		for k, x := range domain {
+			evals[k] = PrimeField.NewFieldElement(composition.Eval(x.Big(), PrimeField.Modulus()))
+		}
+		opts := DefaultProofOptions()
+		opts.NumQueries = 8
+		commitment, proof := proveTranscript(func(tr Transcript) []byte {
+			return FRIProve(evals, domain, 16, tr, opts)
+		})
+		assert.Error(t, verifyTranscript(proof, func(tr Transcript) error {
+			return FRIVerify(commitment, domain, 16, tr, opts)
+		}))
+
+		// the honest composition passes the same test
+		composition = DegreeAdjustedComposition(quotients, degreeBounds, coefficients)
+		for k, x := range domain {
+			evals[k] = PrimeField.NewFieldElement(composition.Eval(x.Big(), PrimeField.Modulus()))
+		}
+		commitment, proof = proveTranscript(func(tr Transcript) []byte {
+			return FRIProve(evals, domain, 16, tr, opts)
+		})
+		assert.NoError(t, verifyTranscript(proof, func(tr Transcript) error {
+			return FRIVerify(commitment, domain, 16, tr, opts)
+		}))
+	})
+}
@@ -0,0 +1,81 @@
+package zkstarks
+
+import (
+	"fmt"
+)
+
+// When the verifier derives different challenges than the prover the
+// transcripts diverged at some operation, in debug mode a channel records
+// every operation with the state hash right after it so that two traces
+// can be compared entry by entry.
+// Challenges drawn together (RandInts, RandFEs) are recorded after the
+// state is ratcheted so they all carry the same state.
+
+// TraceEntry represents a channel operation and the resulting state
+type TraceEntry struct {
+	Operation string `json:"operation"`
+	Label     string `json:"label"`
+	Payload   string `json:"payload"`
+	State     string `json:"state"`
+}
+
+// TraceDiff represents the first operation two traces disagree on
+// A or B is nil when the corresponding trace ended before the other.
+type TraceDiff struct {
+	Index int
+	A     *TraceEntry
+	B     *TraceEntry
+}
+
+// String reports the differing operation
+func (d *TraceDiff) String() string {
+
+	describe := func(e *TraceEntry) string {
+		if e == nil {
+			return "<end of transcript>"
+		}
+		return fmt.Sprintf("%s %s payload=%s state=%s", e.Operation, e.Label, e.Payload, e.State)
+	}
+
+	reason := "transcript lengths differ"
+	switch {
+	case d.A == nil || d.B == nil:
+	case d.A.Operation != d.B.Operation || d.A.Label != d.B.Label:
+		reason = "operations differ"
+	case d.A.Payload != d.B.Payload:
+		reason = "payloads differ"
+	default:
+		reason = "states differ"
+	}
+
+	return fmt.Sprintf("operation %d : %s\n  a: %s\n  b: %s", d.Index, reason, describe(d.A), describe(d.B))
+}
+
+// DiffTraces returns the first operation the traces disagree on or nil
+// if they are identical.
+func DiffTraces(a, b []TraceEntry) *TraceDiff {
+
+	for i := 0; i < len(a) || i < len(b); i++ {
+		diff := &TraceDiff{Index: i}
+		if i < len(a) {
+			diff.A = &a[i]
+		}
+		if i < len(b) {
+			diff.B = &b[i]
+		}
+		if diff.A == nil || diff.B == nil || *diff.A != *diff.B {
+			return diff
+		}
+	}
+	return nil
+}
+
+// EnableDebug makes the transcript record its channel operations
+func (c challenger) EnableDebug() {
+	c.channel.EnableDebug()
+}
+
+// Trace returns the operations recorded in debug mode
+func (c challenger) Trace() []TraceEntry {
+	return c.channel.Trace
+}
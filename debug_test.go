@@ -0,0 +1,70 @@
+package zkstarks
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceDiff(t *testing.T) {
+
+	run := func(ch *Channel, commitment []byte) {
+		ch.EnableDebug()
+		ch.Send("trace.commitment", []byte("root"))
+		ch.RandFEs("composition.coefficient", 2, PrimeField.Modulus())
+		ch.Send("composition.commitment", commitment)
+		ch.RandFE("fri.beta", PrimeField.Modulus())
+	}
+
+	t.Run("TestDebugTrace", func(t *testing.T) {
+		ch := NewChannel()
+		run(ch, []byte{1})
+		assert.Len(t, ch.Trace, 5)
+		assert.Equal(t, "send", ch.Trace[0].Operation)
+		assert.Equal(t, "trace.commitment", ch.Trace[0].Label)
+		assert.Equal(t, hex.EncodeToString([]byte("root")), ch.Trace[0].Payload)
+		assert.Equal(t, ch.Trace[1].State, ch.Trace[2].State)
+		assert.Equal(t, hex.EncodeToString(ch.State), ch.Trace[4].State)
+		assert.Nil(t, NewChannel().Trace)
+	})
+	t.Run("TestDiffTraces", func(t *testing.T) {
+		a, b := NewChannel(), NewChannel()
+		run(a, []byte{1})
+		run(b, []byte{1})
+		assert.Nil(t, DiffTraces(a.Trace, b.Trace))
+
+		c := NewChannel()
+		run(c, []byte{2})
+		diff := DiffTraces(a.Trace, c.Trace)
+		assert.Equal(t, 3, diff.Index)
+		assert.Equal(t, "composition.commitment", diff.A.Label)
+		assert.True(t, strings.Contains(diff.String(), "payloads differ"))
+
+		diff = DiffTraces(a.Trace, a.Trace[:4])
+		assert.Equal(t, 4, diff.Index)
+		assert.Nil(t, diff.B)
+		assert.True(t, strings.Contains(diff.String(), "<end of transcript>"))
+	})
+	t.Run("TestTranscriptDivergence", func(t *testing.T) {
+		prover := NewProverTranscript(NewSHA3Hasher())
+		prover.EnableDebug()
+		_, err := testProtocol(prover, big.NewInt(42))
+		assert.NoError(t, err)
+
+		proof := prover.Proof()
+		proof.Messages[1].Data = []byte{1}
+		verifier, err := NewVerifierTranscript(proof)
+		assert.NoError(t, err)
+		verifier.EnableDebug()
+		_, err = testProtocol(verifier, nil)
+		assert.NoError(t, err)
+
+		diff := DiffTraces(prover.Trace(), verifier.Trace())
+		assert.Equal(t, 2, diff.Index)
+		assert.Equal(t, "answer", diff.B.Label)
+		assert.True(t, strings.Contains(diff.String(), "payloads differ"))
+	})
+}
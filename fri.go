@@ -2,6 +2,7 @@ package zkstarks
 
 import (
 	"math/big"
+	"math/bits"
 
 	"github.com/actuallyachraf/algebra/ff"
 	"github.com/actuallyachraf/algebra/poly"
@@ -113,6 +114,68 @@ func NextFRILayer(domain []ff.FieldElement, p poly.Polynomial, beta ff.FieldElem
 	return nextFRIDomain, nextFRIPoly, nextLayer
 }
 
+// Folding the coefficients then evaluating the next polynomial over the
+// next domain is quadratic in the domain size, the next layer can instead be
+// computed from the evaluations of the previous one.
+// Writing p(x) = E(x^2) + xO(x^2) the next polynomial is E + beta*O and :
+// E(x^2) = (p(x) + p(-x)) / 2
+// O(x^2) = (p(x) - p(-x)) / 2x
+// The domain is a coset of a group of even order so -x is found half a domain
+// away from x, the inverses 1/x are computed once by batch inversion and
+// squared to get the inverses of the next domain.
+
+// FoldFRILayer computes the evaluations of the next FRI polynomial over the
+// next FRI domain given the evaluations over the domain and its inverses.
+func FoldFRILayer(evals []ff.FieldElement, invDomain []ff.FieldElement, beta ff.FieldElement) []ff.FieldElement {
+
+	field := beta.Field()
+	half := len(evals) / 2
+	invTwo := field.NewFieldElementFromInt64(2).Inv()
+	nextLayer := make([]ff.FieldElement, half)
+
+	for i := range nextLayer {
+		x, negX := evals[i], evals[i+half]
+		even := field.Add(x, negX)
+		odd := field.Mul(field.Sub(x, negX), invDomain[i])
+		nextLayer[i] = field.Mul(field.Add(even, field.Mul(beta, odd)), invTwo)
+	}
+
+	return nextLayer
+}
+
+// nextInverseDomain returns the inverses of the next FRI domain
+func nextInverseDomain(invDomain []ff.FieldElement) []ff.FieldElement {
+
+	next := make([]ff.FieldElement, len(invDomain)/2)
+	for i := range next {
+		next[i] = invDomain[i].Square()
+	}
+	return next
+}
+
+// batchInverse inverts the elements using a single field inversion
+func batchInverse(elems []ff.FieldElement) []ff.FieldElement {
+
+	if len(elems) == 0 {
+		return nil
+	}
+	field := elems[0].Field()
+	prefix := make([]ff.FieldElement, len(elems))
+	acc := field.One()
+	for i, elem := range elems {
+		prefix[i] = acc
+		acc = field.Mul(acc, elem)
+	}
+
+	inv := acc.Inv()
+	invs := make([]ff.FieldElement, len(elems))
+	for i := len(elems) - 1; i >= 0; i-- {
+		invs[i] = field.Mul(inv, prefix[i])
+		inv = field.Mul(inv, elems[i])
+	}
+	return invs
+}
+
 // DomainHash returns a merkle root of the domain elements
 func DomainHash(domain []ff.FieldElement) []byte {
 
@@ -147,59 +210,201 @@ func cosetDomainBytes(domain []*big.Int) [][]byte {
 	return domainBytes
 }
 
-// serializeAuditPath serializes a merkle audithash
-func serializeAuditPath(ap []merkle.AuditHash) []byte {
-	var auditPath = make([]byte, 0)
+// Folding by a factor 2^k folds k times in a single round, writing
+// p(x) = sum_t x^t p_t(x^F) for t < F = 2^k the next polynomial is
+// sum_t beta^t p_t which is obtained by k binary folds with beta, beta^2,
+// beta^4... so each round commits to a single layer instead of k.
+// Layers are stored in bit-reversed order : the element at position r is the
+// evaluation at the point of index bitrev(r) in the domain.
+// In this order x and -x are adjacent (positions 2m and 2m+1) and the points
+// {x, zx, z^2x...} (z a F-th root of unity) folded together form a block of
+// F consecutive elements, the next layer is again in bit-reversed order with
+// the fold of the block k at position k.
+// Each leaf of a layer tree holds a block so a query opens a single leaf
+// per layer and folding reads the layer sequentially.
+
+// BitReverse returns the elements permuted in bit-reversed order
+func BitReverse(elems []ff.FieldElement) []ff.FieldElement {
+
+	logn := log2(len(elems))
+	reversed := make([]ff.FieldElement, len(elems))
+	for i, elem := range elems {
+		reversed[bitReverseIndex(i, logn)] = elem
+	}
+	return reversed
+}
+
+// bitReverseIndex reverses the logn least significant bits of i
+func bitReverseIndex(i int, logn int) int {
+	if logn == 0 {
+		return 0
+	}
+	return int(bits.Reverse64(uint64(i)) >> (64 - uint(logn)))
+}
+
+// log2 returns the base 2 logarithm of a power of 2
+func log2(n int) int {
+	return bits.TrailingZeros64(uint64(n))
+}
+
+// foldBitReversed folds a bit-reversed layer given the inverses of its
+// bit-reversed domain.
+func foldBitReversed(evals []ff.FieldElement, invDomain []ff.FieldElement, beta ff.FieldElement) ([]ff.FieldElement, []ff.FieldElement) {
+
+	field := beta.Field()
+	invTwo := field.NewFieldElementFromInt64(2).Inv()
+	nextLayer := make([]ff.FieldElement, len(evals)/2)
+	nextInvDomain := make([]ff.FieldElement, len(evals)/2)
+
+	for m := range nextLayer {
+		x, negX := evals[2*m], evals[2*m+1]
+		even := field.Add(x, negX)
+		odd := field.Mul(field.Sub(x, negX), invDomain[2*m])
+		nextLayer[m] = field.Mul(field.Add(even, field.Mul(beta, odd)), invTwo)
+		nextInvDomain[m] = invDomain[2*m].Square()
+	}
+
+	return nextLayer, nextInvDomain
+}
+
+// FoldFRILayerWithFactor folds the bit-reversed evaluations by the given
+// factor, it returns the next layer and the inverses of the next domain.
+func FoldFRILayerWithFactor(evals []ff.FieldElement, invDomain []ff.FieldElement, beta ff.FieldElement, factor int) ([]ff.FieldElement, []ff.FieldElement) {
 
-	for _, path := range ap {
+	for ; factor > 1; factor /= 2 {
+		evals, invDomain = foldBitReversed(evals, invDomain, beta)
+		beta = beta.Square()
+	}
+	return evals, invDomain
+}
+
+// CosetBytes returns the serialized blocks of a bit-reversed layer folded
+// together by the factor.
+func CosetBytes(layer []ff.FieldElement, factor int) [][]byte {
+	return cosetLeaves(layer, factor, nil)
+}
+
+// cosetLeaves returns the blocks of the layer encoded as leaves of a tree
+// hashed with the hash function.
+func cosetLeaves(layer []ff.FieldElement, factor int, hasher Hasher) [][]byte {
+
+	cosetBytes := make([][]byte, len(layer)/factor)
+	for j := range cosetBytes {
+		cosetBytes[j] = encodeElements(hasher, layer[j*factor:(j+1)*factor])
+	}
+	return cosetBytes
+}
+
+// CommitFRILayer commits to evaluations given in the domain's order, they
+// are bit-reversed and each leaf holds a block folded together by the factor.
+func CommitFRILayer(evals []ff.FieldElement, factor int, capHeight int, hasher Hasher) *MerkleTree {
+	return NewMerkleTreeWithHasher(cosetLeaves(BitReverse(evals), factor, hasher), capHeight, hasher)
+}
 
-		var b = make([]byte, 0, 33)
-		copy(b, path.Val)
-		if path.RightOperator {
-			b = append(b, 1)
-		} else {
-			b = append(b, 0)
+// Folding until the polynomial is constant commits to layers of a few
+// elements each costing a root and authentication paths, instead folding
+// stops once the degree bound is below the remainder degree bound and the
+// prover sends the remainder polynomial's coefficients in the clear.
+// The last layer isn't committed, the verifier evaluates the remainder
+// at the queried points and compares it with the folded values.
+
+// RemainderPolynomial interpolates the polynomial with less than degreeBound
+// coefficients taking the values of the layer over the domain.
+func RemainderPolynomial(domain []ff.FieldElement, layer []ff.FieldElement, degreeBound int) []ff.FieldElement {
+
+	points := make([]poly.Point, degreeBound)
+	for i := range points {
+		points[i] = poly.NewPoint(domain[i].Big(), layer[i].Big())
+	}
+	p := poly.Lagrange(points, PrimeField.Modulus())
+
+	coeffs := make([]ff.FieldElement, degreeBound)
+	for i := range coeffs {
+		coeffs[i] = PrimeField.Zero()
+		if i < len(p) {
+			coeffs[i] = PrimeField.NewFieldElement(p[i])
 		}
-		auditPath = append(auditPath, b...)
 	}
-	return auditPath
+	return coeffs
+}
 
+// GenerateFRICommitment given the degree bound of the composition polynomial
+// (its number of coefficients), the evaluation domain, the evaluations on said
+// domain and the merkle tree committing to them with CommitFRILayer returns
+// the FRI domains, layers (both in bit-reversed order) and the merkle tree of
+// each committed layer using the default proof options i.e each round halves
+// the domain until the polynomial is constant.
+// Layers are folded from the evaluations so the composition polynomial's
+// coefficients aren't needed.
+// Layer trees are committed with the same cap height and hash function
+// as the composition tree, their commitments are sent to the caller's
+// channel so that the query indices drawn afterwards depend on them.
+func GenerateFRICommitment(degreeBound int, domain []ff.FieldElement, compositionEvals []ff.FieldElement, compositionTree *MerkleTree, channel *Channel) ([][]ff.FieldElement, [][]ff.FieldElement, []*MerkleTree) {
+	return GenerateFRICommitmentWithOptions(degreeBound, domain, compositionEvals, compositionTree, channel, DefaultProofOptions())
 }
 
-// GenerateFRICommitment given the composition polynomial
-// the evaluation domain, the evaluations on said domain and
-// the first commitment root.
-func GenerateFRICommitment(compositionPoly poly.Polynomial, domain []ff.FieldElement, compositionEvals []ff.FieldElement, compositionRoot []byte, fs Channel) ([][]ff.FieldElement, []poly.Polynomial, [][]ff.FieldElement, [][]byte) {
+// GenerateFRICommitmentWithOptions is GenerateFRICommitment where each round
+// folds the domain by the folding factor (a power of 2) until the degree
+// bound is at most the remainder degree bound.
+func GenerateFRICommitmentWithOptions(degreeBound int, domain []ff.FieldElement, compositionEvals []ff.FieldElement, compositionTree *MerkleTree, channel *Channel, opts ProofOptions) ([][]ff.FieldElement, [][]ff.FieldElement, []*MerkleTree) {
+	requireFRI(opts)
+	return commitFRILayers(degreeBound, domain, compositionEvals, compositionTree, channelTranscript(channel), opts)
+}
 
-	FRIPolynomials := []poly.Polynomial{compositionPoly}
-	FRIDomains := [][]ff.FieldElement{domain}
-	FRILayers := [][]ff.FieldElement{compositionEvals}
-	FRIMerkleRoots := [][]byte{compositionRoot}
+// commitFRILayers runs the commit phase of FRI on a prover transcript
+func commitFRILayers(degreeBound int, domain []ff.FieldElement, compositionEvals []ff.FieldElement, compositionTree *MerkleTree, tr Transcript, opts ProofOptions) ([][]ff.FieldElement, [][]ff.FieldElement, []*MerkleTree) {
 
-	iter := FRIPolynomials[len(FRIPolynomials)-1]
-	field := PrimeField
+	factor := opts.FoldingFactor
+	if factor < 2 || factor&(factor-1) != 0 {
+		panic("folding factor must be a power of 2")
+	}
+	if opts.RemainderDegreeBound < 1 || degreeBound <= opts.RemainderDegreeBound {
+		panic("degree bound must exceed the remainder degree bound")
+	}
+	if compositionTree.Size()*factor != len(compositionEvals) {
+		panic("composition tree must be committed with CommitFRILayer")
+	}
 
-	for iter.Degree() > 0 {
+	FRIDomains := [][]ff.FieldElement{BitReverse(domain)}
+	FRILayers := [][]ff.FieldElement{BitReverse(compositionEvals)}
+	FRIMerkleTrees := []*MerkleTree{compositionTree}
 
-		beta := field.NewFieldElement(fs.RandFE(PrimeField.Modulus()))
+	invDomain := batchInverse(FRIDomains[0])
+	field := PrimeField
 
-		nextFRIDomain, nextFRIPoly, nextFRILayer := NextFRILayer(FRIDomains[len(FRIDomains)-1], FRIPolynomials[len(FRIPolynomials)-1], beta)
+	for degreeBound > opts.RemainderDegreeBound {
 
-		root := DomainHash(nextFRILayer)
+		if len(FRIDomains[len(FRIDomains)-1])%factor != 0 {
+			panic("FRI domain is too small for the folding factor")
+		}
+		beta := field.NewFieldElement(tr.RandFE("fri.beta", PrimeField.Modulus()))
+
+		// the block k is folded to the first element of the block to the
+		// power of the factor
+		currentDomain := FRIDomains[len(FRIDomains)-1]
+		nextFRIDomain := make([]ff.FieldElement, len(currentDomain)/factor)
+		for k := range nextFRIDomain {
+			nextFRIDomain[k] = currentDomain[k*factor].Exp(big.NewInt(int64(factor)))
+		}
+		var nextFRILayer []ff.FieldElement
+		nextFRILayer, invDomain = FoldFRILayerWithFactor(FRILayers[len(FRILayers)-1], invDomain, beta, factor)
 
 		FRIDomains = append(FRIDomains, nextFRIDomain)
-		FRIPolynomials = append(FRIPolynomials, nextFRIPoly)
 		FRILayers = append(FRILayers, nextFRILayer)
-		FRIMerkleRoots = append(FRIMerkleRoots, root)
-
-		fs.Send(FRIMerkleRoots[len(FRIMerkleRoots)-1])
 
-		iter = FRIPolynomials[len(FRIPolynomials)-1]
+		degreeBound = (degreeBound + factor - 1) / factor
+		if degreeBound <= opts.RemainderDegreeBound {
+			break
+		}
+		tree := NewMerkleTreeWithHasher(cosetLeaves(nextFRILayer, factor, compositionTree.Hasher()), compositionTree.CapHeight(), compositionTree.Hasher())
+		FRIMerkleTrees = append(FRIMerkleTrees, tree)
 
+		proverMessage(tr, "fri.commitment", tree.Commitment())
 	}
-	fs.Send(FRIPolynomials[len(FRIPolynomials)-1][0].Bytes())
+	remainder := RemainderPolynomial(FRIDomains[len(FRIDomains)-1], FRILayers[len(FRILayers)-1], opts.RemainderDegreeBound)
+	proverMessage(tr, "fri.remainder", encodeElements(tr.Hasher(), remainder))
 
-	return FRIDomains, FRIPolynomials, FRILayers, FRIMerkleRoots
+	return FRIDomains, FRILayers, FRIMerkleTrees
 }
 
 // In order to verify the commitment proofs we need to implement to new functions
@@ -207,108 +412,81 @@ func GenerateFRICommitment(compositionPoly poly.Polynomial, domain []ff.FieldEle
 // is consistent with the others ,the second will send the data required to
 // decommit on the trace polynomial.
 // This part deals mainly with non-interactiveness of our proof system.
+// All queries are decommitted at once so that each merkle tree sends a single
+// multi-proof for every leaf opened in it.
+
+// DecommitFRILayers iterates over the fri-layers merkle trees and sends the
+// following data trough the FS channel :
+// - The leaf holding the block folded with the queried element as "fri.coset"
+// - The merkle multi-proof of the opened leaves.
+// Indices are indices in the evaluation domain, the queried element of the
+// first layer is at position bitrev(index) and the folded value of the block
+// holding the position p is at position p/factor in the next layer.
+func DecommitFRILayers(indices []int, channel *Channel, friTrees []*MerkleTree, factor int) {
+	decommitFRILayers(indices, channelTranscript(channel), friTrees, factor)
+}
 
-// DecommitFRILayers iterates over the fri-layers and fri-roots (except the last ones)
-// as those are constant and sends
-// the following data trough the FS channel :
-// - Element of the FRI layer at the given index
-// - It's merkle proof
-// - Sibling Element on the fri-layer if the element is cp_i(x) it's sibling
-// is cp_i(-x)
-// - The merkle proof of the sibling.
-func DecommitFRILayers(index int, channel *Channel, friLayers [][]ff.FieldElement) {
-
-	for i := 0; i < len(friLayers)-1; i++ {
-		layer := friLayers[i]
-		length := len(layer)
-		index = index % length
-		siblingIndex := (index + (length / 2)) % length
-
-		elemBytes := layer[index].Big().Bytes()
-		elemProof, err := merkle.Proof(DomainBytes(layer), index)
+// decommitFRILayers runs the query phase of FRI on a prover transcript
+func decommitFRILayers(indices []int, tr Transcript, friTrees []*MerkleTree, factor int) {
 
-		if err != nil {
-			panic(err)
+	size := friTrees[0].Size() * factor
+	positions := make([]int, len(indices))
+	for j, index := range indices {
+		positions[j] = bitReverseIndex(index%size, log2(size))
+	}
+
+	for _, tree := range friTrees {
+		opened := make([]int, 0, len(positions))
+
+		for j := range positions {
+			positions[j] /= factor
+			proverMessage(tr, "fri.coset", tree.Leaf(positions[j]))
+			opened = append(opened, positions[j])
 		}
-		siblingBytes := layer[siblingIndex].Big().Bytes()
-		siblingProof, err := merkle.Proof(DomainBytes(layer), siblingIndex)
+		multiProof, err := tree.OpenMulti(opened)
 		if err != nil {
 			panic(err)
 		}
-		elemProofBytes := serializeAuditPath(elemProof)
-		siblingProofBytes := serializeAuditPath(siblingProof)
-
-		channel.Send(elemBytes)
-		channel.Send(elemProofBytes)
-		channel.Send(siblingBytes)
-		channel.Send(siblingProofBytes)
-
+		proverMessage(tr, "fri.multiproof", serializeMultiProof(multiProof))
 	}
-	// Send the last layer element
-	channel.Send(friLayers[len(friLayers)-1][0].Big().Bytes())
 }
 
 // Decommiting on the trace polynomial involves verifying the evaluation
 // of the composition polynomial
-// The value f(x) with its authentication path.
-// The value f(gx) with its authentication path.
-// The value f(g^2x) with its authentication path.
+// The value f(x).
+// The value f(gx).
+// The value f(g^2x).
+// The multi-proof authenticating all of them.
 // The verifier, knowing the random coefficients of the composition polynomial,
 // can compute its evaluation at x, and compare it with the first element sent from the first FRI layer.
 
-// DecommitOnQuery takes an index, a channel, coset evaluations and sends
-// the evaluations and their proofs at the given index
-func DecommitOnQuery(index int, channel *Channel, cosetEval []*big.Int, friLayers [][]ff.FieldElement) {
-
-	if index+16 > len(cosetEval) {
-		panic("coset eval index out of range")
-	}
-
-	cosetBytes := cosetDomainBytes(cosetEval)
+// DecommitOnQueries takes query indices, a channel, the merkle tree of the coset
+// evaluations and sends the evaluations at the given indices and their multi-proof
+// then decommits on the FRI layers folded by the given factor.
+func DecommitOnQueries(indices []int, channel *Channel, cosetTree *MerkleTree, friTrees []*MerkleTree, factor int) {
 
-	firstEvalBytes := cosetBytes[index]
-	firstEvalAP, err := merkle.Proof(cosetBytes, index)
-	if err != nil {
-		panic(err)
-	}
-	firstEvalAPSerialized := serializeAuditPath(firstEvalAP)
+	opened := make([]int, 0, 3*len(indices))
 
-	channel.Send(firstEvalBytes)
-	channel.Send(firstEvalAPSerialized)
-
-	secondEvalBytes := cosetBytes[index+8]
-	secondEvalAP, err := merkle.Proof(cosetBytes, index+8)
-	if err != nil {
-		panic(err)
+	for _, index := range indices {
+		if index < 0 || index+16 >= cosetTree.Size() {
+			panic("coset eval index out of range")
+		}
+		for _, offset := range []int{0, 8, 16} {
+			channel.Send("trace.evaluation", cosetTree.Leaf(index+offset))
+			opened = append(opened, index+offset)
+		}
 	}
-	secondEvalAPSerialized := serializeAuditPath(secondEvalAP)
-
-	channel.Send(secondEvalBytes)
-	channel.Send(secondEvalAPSerialized)
-
-	thirdEvalBytes := cosetBytes[index+16]
-	thirdEvalAP, err := merkle.Proof(cosetBytes, index+16)
+	multiProof, err := cosetTree.OpenMulti(opened)
 	if err != nil {
 		panic(err)
 	}
-	thirdEvalAPSerialized := serializeAuditPath(thirdEvalAP)
+	channel.Send("trace.multiproof", serializeMultiProof(multiProof))
 
-	channel.Send(thirdEvalBytes)
-	channel.Send(thirdEvalAPSerialized)
-
-	DecommitFRILayers(index, channel, friLayers)
+	DecommitFRILayers(indices, channel, friTrees, factor)
 }
 
 // FRIDecommit receives random values from the verifier (using FS)
-// and decommits on each query index.
-func FRIDecommit(channel *Channel, cosetEval []*big.Int, friLayers [][]ff.FieldElement) {
-
-	lb := big.NewInt(0)
-	ub := big.NewInt(8196 - 16)
-
-	for i := 0; i < 3; i++ {
-		randIdx := channel.RandInt(lb, ub)
-
-		DecommitOnQuery(int(randIdx.Int64()), channel, cosetEval, friLayers)
-	}
+// and decommits on the query indices.
+func FRIDecommit(channel *Channel, cosetTree *MerkleTree, friTrees []*MerkleTree) {
+	FRIDecommitWithOptions(channel, cosetTree, friTrees, DefaultProofOptions())
 }
@@ -1,9 +1,13 @@
 package zkstarks
 
 import (
+	"encoding/hex"
+	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/actuallyachraf/algebra/ff"
+	"github.com/actuallyachraf/algebra/nt"
 	"github.com/actuallyachraf/algebra/poly"
 	"github.com/stretchr/testify/assert"
 )
@@ -38,5 +42,184 @@ func TestFRIOperations(t *testing.T) {
 		assert.Equal(t, nextDomain, actualNextDomain)
 		assert.Equal(t, nextLayer, actualNextLayer)
 	})
+	t.Run("TestFoldFRILayer", func(t *testing.T) {
+		p, domain, evals, _ := testFRIInstance(32, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+		beta := PrimeField.NewFieldElementFromInt64(7)
+
+		invDomain := batchInverse(domain)
+		for i := range domain {
+			assert.True(t, PrimeField.Mul(domain[i], invDomain[i]).Equal(PrimeField.One()))
+		}
+
+		_, nextPoly, nextLayer := NextFRILayer(domain, p, beta)
+		assert.Equal(t, nextLayer, FoldFRILayer(evals, invDomain, beta))
+
+		nextDomain := NextFRIDomain(domain)
+		nextInvDomain := nextInverseDomain(invDomain)
+		for i := range nextDomain {
+			assert.True(t, PrimeField.Mul(nextDomain[i], nextInvDomain[i]).Equal(PrimeField.One()))
+		}
+		_, _, lastLayer := NextFRILayer(nextDomain, nextPoly, beta)
+		assert.Equal(t, lastLayer, FoldFRILayer(nextLayer, nextInvDomain, beta))
+	})
+	t.Run("TestFRICommitmentFromEvaluations", func(t *testing.T) {
+		_, domain, evals, tree := testFRIInstance(32, 1, 2, 3, 4, 5, 6, 7, 8)
+
+		domains, layers, trees := GenerateFRICommitment(8, domain, evals, tree, NewChannel())
+		assert.Len(t, layers, 4)
+		assert.Len(t, domains, 4)
+		assert.Len(t, trees, 3)
+		for _, x := range layers[3] {
+			assert.True(t, x.Equal(layers[3][0]))
+		}
+		assert.Equal(t, BitReverse(domain), domains[0])
+		assert.Equal(t, BitReverse(evals), layers[0])
+	})
+	t.Run("TestBitReverse", func(t *testing.T) {
+		elems := make([]ff.FieldElement, 8)
+		for i := range elems {
+			elems[i] = PrimeField.NewFieldElementFromInt64(int64(i))
+		}
+		reversed := BitReverse(elems)
+		for i, expected := range []int64{0, 4, 2, 6, 1, 5, 3, 7} {
+			assert.True(t, reversed[i].Equal(PrimeField.NewFieldElementFromInt64(expected)))
+		}
+		assert.Equal(t, elems, BitReverse(reversed))
+
+		// x and -x are adjacent in a bit-reversed domain
+		_, domain, _, _ := testFRIInstance(32, 0)
+		reversedDomain := BitReverse(domain)
+		for m := 0; m < len(domain)/2; m++ {
+			assert.True(t, PrimeField.Add(reversedDomain[2*m], reversedDomain[2*m+1]).Equal(PrimeField.Zero()))
+		}
+	})
+
+	t.Run("TestFRICommitmentExtendsChannel", func(t *testing.T) {
+		p, domain, evals, tree := testFRIInstance(32, 1, 2, 3, 4, 5, 6, 7, 8)
+
+		channel := NewChannel()
+		channel.Send("composition.commitment", tree.Commitment())
+		before := append([]byte(nil), channel.State...)
+
+		_, _, friTrees := GenerateFRICommitment(len(p), domain, evals, tree, channel)
+
+		assert.NotEqual(t, before, channel.State)
+		commitments := 0
+		for _, entry := range channel.Proof {
+			if strings.HasPrefix(entry, "send:fri.commitment:") {
+				commitments++
+			}
+		}
+		assert.Equal(t, len(friTrees)-1, commitments)
+		assert.Equal(t, "send:fri.remainder", channel.Proof[len(channel.Proof)-1][:18])
+	})
+	t.Run("TestQueriesDependOnEveryCommitment", func(t *testing.T) {
+		p, domain, evals, tree := testFRIInstance(32, 1, 2, 3, 4, 5, 6, 7, 8)
+
+		channel := NewChannel()
+		channel.Send("composition.commitment", tree.Commitment())
+		GenerateFRICommitment(len(p), domain, evals, tree, channel)
+		lb, ub := nt.FromInt64(0), nt.FromInt64(31)
+		queries := channel.RandInts("query.index", 4, lb, ub)
+
+		// replaying the transcript yields the same queries
+		assert.Equal(t, queries, replayFRITranscript(t, channel.Proof, -1).RandInts("query.index", 4, lb, ub))
+
+		// altering any sent message changes the queries
+		for i, entry := range channel.Proof {
+			if strings.HasPrefix(entry, "send:") {
+				assert.NotEqual(t, queries, replayFRITranscript(t, channel.Proof, i).RandInts("query.index", 4, lb, ub))
+			}
+		}
+	})
+}
+
+// testFRIInstance evaluates the polynomial with the given coefficients on a
+// coset of the subgroup of the given size and commits to the evaluations
+// for a folding factor of 2.
+func testFRIInstance(size int64, coeffs ...int) (poly.Polynomial, []ff.FieldElement, []ff.FieldElement, *MerkleTree) {
+
+	order := new(big.Int).Sub(PrimeField.Modulus(), big.NewInt(1))
+	g := PrimeFieldGen.Exp(order.Div(order, big.NewInt(size)))
+
+	p := poly.NewPolynomialInts(coeffs...)
+	domain := make([]ff.FieldElement, size)
+	evals := make([]ff.FieldElement, size)
+	for i := range domain {
+		domain[i] = PrimeField.Mul(PrimeFieldGen, g.Exp(big.NewInt(int64(i))))
+		evals[i] = PrimeField.NewFieldElement(p.Eval(domain[i].Big(), PrimeField.Modulus()))
+	}
+
+	return p, domain, evals, CommitFRILayer(evals, 2, 0, NewSHA3Hasher())
+}
+
+// proveTranscript runs a prover on a new transcript and returns its
+// commitment and proof.
+func proveTranscript(prove func(tr Transcript) []byte) ([]byte, *Proof) {
+	return proveTranscriptWithHasher(NewSHA3Hasher(), prove)
+}
+
+// proveTranscriptWithHasher runs a prover on a new transcript using the
+// given hash function.
+func proveTranscriptWithHasher(h Hasher, prove func(tr Transcript) []byte) ([]byte, *Proof) {
+
+	tr := NewProverTranscript(h)
+	commitment := prove(tr)
+	return commitment, tr.Proof()
+}
+
+// verifyTranscript runs a verifier on the proof and checks that every
+// message was read.
+func verifyTranscript(proof *Proof, verify func(tr Transcript) error) error {
+
+	tr, err := NewVerifierTranscript(proof)
+	if err != nil {
+		return err
+	}
+	if err := verify(tr); err != nil {
+		return err
+	}
+	return tr.Finalize()
+}
+
+// assertRejectsTamperedMessages flips the last byte of each message of the
+// proof with one of the given labels and checks the verifier rejects it.
+func assertRejectsTamperedMessages(t *testing.T, proof *Proof, verify func(proof *Proof) error, labels ...string) {
+
+	tampered := 0
+	for i, msg := range proof.Messages {
+		for _, label := range labels {
+			if msg.Label != label {
+				continue
+			}
+			forged := &Proof{Hash: proof.Hash, Messages: append([]ProofMessage(nil), proof.Messages...)}
+			forged.Messages[i].Data = append([]byte(nil), msg.Data...)
+			forged.Messages[i].Data[len(msg.Data)-1] ^= 1
+			assert.Error(t, verify(forged), "message %d %s", i, msg.Label)
+			tampered++
+		}
+	}
+	assert.NotZero(t, tampered, "no message labelled %v", labels)
+}
+
+// replayFRITranscript replays the sends of a recorded transcript on a new
+// channel, the message at index tamper (if any) has its first byte flipped.
+func replayFRITranscript(t *testing.T, proof []string, tamper int) *Channel {
 
+	channel := NewChannel()
+	for i, entry := range proof {
+		parts := strings.Split(entry, ":")
+		switch parts[0] + ":" {
+		case sendOperator:
+			data, err := hex.DecodeString(parts[2])
+			assert.NoError(t, err)
+			if i == tamper {
+				data[0] ^= 1
+			}
+			channel.Send(parts[1], data)
+		case receiveRandFE:
+			channel.RandFE(parts[1], PrimeField.Modulus())
+		}
+	}
+	return channel
 }
@@ -0,0 +1,236 @@
+package zkstarks
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"github.com/actuallyachraf/algebra/poly"
+)
+
+// The FRI verifier replays the prover's messages from a transcript :
+// - during the commit phase it reads each layer commitment and derives
+// the same folding challenges as the prover
+// - during the query phase it reads the cosets opened in each layer and
+// their multi-proofs, folds each coset and checks the folded value against
+// the coset opened in the next layer and finally against the remainder
+// polynomial evaluated at the folded point.
+// A coset {x, zx, z^2x...} of values p(x), p(zx)... is folded by
+// interpolating the polynomial q of degree less than the factor through
+// them, q is p mod (X^F - x^F) so the folded value sum_t beta^t p_t(x^F)
+// is q(beta).
+
+// FRIVerifier represents the verifier of the FRI protocol
+type FRIVerifier struct {
+	domain         []ff.FieldElement
+	degreeBound    int
+	factor         int
+	remainderBound int
+	capHeight      int
+	hasher         Hasher
+	betas          []ff.FieldElement
+	caps           [][][]byte
+	remainder      poly.Polynomial
+	firstLayer     [][]ff.FieldElement
+}
+
+// NewFRIVerifier creates a verifier of the FRI protocol for evaluations
+// over the domain of a polynomial with the given degree bound.
+func NewFRIVerifier(domain []ff.FieldElement, degreeBound int, hasher Hasher, opts ProofOptions) *FRIVerifier {
+	return &FRIVerifier{
+		domain:         domain,
+		degreeBound:    degreeBound,
+		factor:         opts.FoldingFactor,
+		remainderBound: opts.RemainderDegreeBound,
+		capHeight:      opts.CapHeight,
+		hasher:         hasher,
+	}
+}
+
+// ReadCommitments reads the layer commitments from the transcript and
+// derives the folding challenges, compositionCommitment is the commitment
+// to the first layer.
+func (v *FRIVerifier) ReadCommitments(tr Transcript, compositionCommitment []byte) error {
+
+	if v.factor < 2 || v.factor&(v.factor-1) != 0 {
+		return errors.New("folding factor must be a power of 2")
+	}
+	if v.remainderBound < 1 || v.degreeBound <= v.remainderBound {
+		return errors.New("degree bound must exceed the remainder degree bound")
+	}
+
+	size := len(v.domain)
+	if size%v.factor != 0 {
+		return errors.New("FRI domain is too small for the folding factor")
+	}
+	cap, err := parseCap(v.hasher, compositionCommitment, size/v.factor, v.capHeight)
+	if err != nil {
+		return err
+	}
+	v.caps = [][][]byte{cap}
+	v.betas = nil
+
+	for bound := v.degreeBound; bound > v.remainderBound; {
+		if size%v.factor != 0 {
+			return errors.New("FRI domain is too small for the folding factor")
+		}
+		size /= v.factor
+
+		v.betas = append(v.betas, PrimeField.NewFieldElement(tr.RandFE("fri.beta", PrimeField.Modulus())))
+		bound = (bound + v.factor - 1) / v.factor
+		if bound <= v.remainderBound {
+			break
+		}
+		commitment, err := tr.Message("fri.commitment", nil)
+		if err != nil {
+			return err
+		}
+		if size%v.factor != 0 {
+			return errors.New("FRI domain is too small for the folding factor")
+		}
+		cap, err := parseCap(v.hasher, commitment, size/v.factor, v.capHeight)
+		if err != nil {
+			return err
+		}
+		v.caps = append(v.caps, cap)
+	}
+
+	remainder, err := tr.Message("fri.remainder", nil)
+	if err != nil {
+		return err
+	}
+	coeffs, err := decodeElements(v.hasher, remainder)
+	if err != nil || len(coeffs) != v.remainderBound {
+		return errors.New("bad FRI remainder polynomial")
+	}
+	v.remainder = poly.NewPolynomial(coeffs)
+
+	return nil
+}
+
+// VerifyQueries reads the decommitments of the FRI layers on the query
+// indices and checks their consistency.
+// Layers are committed in bit-reversed order so the query at position p of
+// a layer opens the leaf p/factor holding the whole folded block and the
+// queried element is its (p mod factor)-th value.
+func (v *FRIVerifier) VerifyQueries(tr Transcript, indices []int) error {
+
+	size := len(v.domain)
+	positions := make([]int, len(indices))
+	for q, index := range indices {
+		positions[q] = bitReverseIndex(index%size, log2(size))
+	}
+	folded := make([]ff.FieldElement, len(indices))
+	exponent := big.NewInt(1)
+	v.firstLayer = make([][]ff.FieldElement, len(indices))
+
+	for i, beta := range v.betas {
+		opened := make([]int, 0, len(indices))
+		leaves := make([][]byte, 0, len(indices))
+
+		for q, position := range positions {
+			leaf, slot := position/v.factor, position%v.factor
+			coset, openedLeaf, err := v.readCoset(tr, i)
+			if err != nil {
+				return err
+			}
+			if i == 0 {
+				v.firstLayer[q] = coset
+			}
+			if i > 0 && !coset[slot].Equal(folded[q]) {
+				return fmt.Errorf("FRI layer %d isn't consistent with layer %d at query %d", i, i-1, q)
+			}
+			opened = append(opened, leaf)
+			leaves = append(leaves, openedLeaf)
+
+			xs := make([]ff.FieldElement, v.factor)
+			for t := range xs {
+				xs[t] = v.domain[bitReverseIndex(leaf*v.factor+t, log2(size))].Exp(exponent)
+			}
+			folded[q] = interpolateAt(xs, coset, beta)
+			positions[q] = leaf
+		}
+
+		proof, err := tr.Message("fri.multiproof", nil)
+		if err != nil {
+			return err
+		}
+		if !VerifyMerkleCapMultiProof(v.hasher, v.caps[i], size/v.factor, opened, leaves, v.splitNodes(proof)) {
+			return fmt.Errorf("bad multi-proof for FRI layer %d", i)
+		}
+
+		size /= v.factor
+		exponent.Mul(exponent, big.NewInt(int64(v.factor)))
+	}
+
+	for q, position := range positions {
+		x := v.domain[bitReverseIndex(position, log2(size))].Exp(exponent)
+		expected := PrimeField.NewFieldElement(v.remainder.Eval(x.Big(), PrimeField.Modulus()))
+		if !folded[q].Equal(expected) {
+			return fmt.Errorf("last FRI layer doesn't match the remainder at query %d", q)
+		}
+	}
+
+	return nil
+}
+
+// FirstLayerCosets returns the blocks of the first layer opened on each
+// query by the last call to VerifyQueries.
+func (v *FRIVerifier) FirstLayerCosets() [][]ff.FieldElement {
+	return v.firstLayer
+}
+
+// readCoset reads the next block opened in the i-th layer
+func (v *FRIVerifier) readCoset(tr Transcript, i int) ([]ff.FieldElement, []byte, error) {
+
+	leaf, err := tr.Message("fri.coset", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	coset, err := decodeElements(v.hasher, leaf)
+	if err != nil || len(coset) != v.factor {
+		return nil, nil, fmt.Errorf("bad coset in FRI layer %d", i)
+	}
+	return coset, leaf, nil
+}
+
+// splitNodes splits concatenated hashes
+func (v *FRIVerifier) splitNodes(b []byte) [][]byte {
+	return splitHashes(v.hasher, b)
+}
+
+// splitHashes splits concatenated hashes of the hash function, it returns
+// nil if the length isn't a multiple of the hash size.
+func splitHashes(hasher Hasher, b []byte) [][]byte {
+
+	size := len(hasher.Hash())
+	if len(b)%size != 0 {
+		return nil
+	}
+	nodes := make([][]byte, 0, len(b)/size)
+	for ; len(b) >= size; b = b[size:] {
+		nodes = append(nodes, b[:size])
+	}
+	return nodes
+}
+
+// interpolateAt evaluates at x the polynomial of degree less than len(xs)
+// taking the values ys at the points xs.
+func interpolateAt(xs, ys []ff.FieldElement, x ff.FieldElement) ff.FieldElement {
+
+	field := x.Field()
+	result := field.Zero()
+	for i := range xs {
+		num, den := field.One(), field.One()
+		for j := range xs {
+			if i == j {
+				continue
+			}
+			num = field.Mul(num, field.Sub(x, xs[j]))
+			den = field.Mul(den, field.Sub(xs[i], xs[j]))
+		}
+		result = field.Add(result, field.Mul(ys[i], field.Div(num, den)))
+	}
+	return result
+}
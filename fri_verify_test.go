@@ -0,0 +1,178 @@
+package zkstarks
+
+import (
+	"testing"
+
+	"github.com/actuallyachraf/algebra/nt"
+	"github.com/stretchr/testify/assert"
+)
+
+// proveFRI commits to the evaluations of the polynomial with the given
+// coefficients, decommits on 4 queries and returns the proof.
+func proveFRI(opts ProofOptions, degreeBound int, coeffs ...int) *Proof {
+
+	_, domain, evals, _ := testFRIInstance(64, coeffs...)
+	tree := CommitFRILayer(evals, opts.FoldingFactor, opts.CapHeight, NewSHA3Hasher())
+
+	channel := NewChannel()
+	channel.Send("composition.commitment", tree.Commitment())
+	_, _, friTrees := GenerateFRICommitmentWithOptions(degreeBound, domain, evals, tree, channel, opts)
+
+	indices := make([]int, 4)
+	for i, index := range channel.RandInts("query.index", len(indices), nt.FromInt64(0), nt.FromInt64(63)) {
+		indices[i] = int(index.Int64())
+	}
+	DecommitFRILayers(indices, channel, friTrees, opts.FoldingFactor)
+
+	proof, err := ParseProofLog(channel.Proof)
+	if err != nil {
+		panic(err)
+	}
+	return proof
+}
+
+// verifyFRI replays a proof produced by proveFRI
+func verifyFRI(proof *Proof, opts ProofOptions, degreeBound int) error {
+
+	_, domain, _, _ := testFRIInstance(64, 0)
+	tr, err := NewVerifierTranscript(proof)
+	if err != nil {
+		return err
+	}
+	commitment, err := tr.Message("composition.commitment", nil)
+	if err != nil {
+		return err
+	}
+	verifier := NewFRIVerifier(domain, degreeBound, NewSHA3Hasher(), opts)
+	if err := verifier.ReadCommitments(tr, commitment); err != nil {
+		return err
+	}
+
+	indices := make([]int, 4)
+	for i, index := range tr.RandInts("query.index", len(indices), nt.FromInt64(0), nt.FromInt64(63)) {
+		indices[i] = int(index.Int64())
+	}
+	if err := verifier.VerifyQueries(tr, indices); err != nil {
+		return err
+	}
+	return tr.Finalize()
+}
+
+func TestFRIVerifier(t *testing.T) {
+
+	coeffs := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+	t.Run("TestFoldByInterpolation", func(t *testing.T) {
+		_, domain, evals, _ := testFRIInstance(64, coeffs...)
+		domain, evals = BitReverse(domain), BitReverse(evals)
+		beta := PrimeField.NewFieldElementFromInt64(11)
+
+		for _, factor := range []int{2, 4, 8, 16} {
+			next, _ := FoldFRILayerWithFactor(evals, batchInverse(domain), beta, factor)
+			assert.Len(t, next, 64/factor)
+
+			for j := range next {
+				xs := domain[j*factor : (j+1)*factor]
+				ys := evals[j*factor : (j+1)*factor]
+				assert.True(t, interpolateAt(xs, ys, beta).Equal(next[j]))
+			}
+		}
+	})
+	t.Run("TestFewerLayers", func(t *testing.T) {
+		_, domain, evals, _ := testFRIInstance(64, coeffs...)
+		for factor, expected := range map[int]int{2: 5, 4: 3, 16: 2} {
+			opts := DefaultProofOptions()
+			opts.FoldingFactor = factor
+			tree := CommitFRILayer(evals, factor, 0, NewSHA3Hasher())
+			_, layers, trees := GenerateFRICommitmentWithOptions(16, domain, evals, tree, NewChannel(), opts)
+			assert.Len(t, layers, expected)
+			assert.Len(t, trees, expected-1)
+			for _, x := range layers[len(layers)-1] {
+				assert.True(t, x.Equal(layers[len(layers)-1][0]))
+			}
+		}
+	})
+	t.Run("TestVerifyFRI", func(t *testing.T) {
+		for _, factor := range []int{2, 4, 8, 16} {
+			opts := DefaultProofOptions()
+			opts.FoldingFactor = factor
+			proof := proveFRI(opts, 16, coeffs...)
+			assert.NoError(t, verifyFRI(proof, opts, 16), "factor %d", factor)
+		}
+	})
+	t.Run("TestRemainderPolynomial", func(t *testing.T) {
+		for _, bound := range []int{2, 4, 8} {
+			opts := DefaultProofOptions()
+			opts.RemainderDegreeBound = bound
+			proof := proveFRI(opts, 16, coeffs...)
+			assert.NoError(t, verifyFRI(proof, opts, 16), "remainder bound %d", bound)
+
+			remainder := proof.Messages[len(proof.Messages)-1]
+			for _, msg := range proof.Messages {
+				if msg.Label == "fri.remainder" {
+					remainder = msg
+				}
+			}
+			assert.Len(t, remainder.Data, 4*bound)
+		}
+		opts := DefaultProofOptions()
+		opts.FoldingFactor, opts.RemainderDegreeBound = 4, 4
+		_, domain, evals, _ := testFRIInstance(64, coeffs...)
+		tree := CommitFRILayer(evals, 4, 0, NewSHA3Hasher())
+		_, layers, trees := GenerateFRICommitmentWithOptions(16, domain, evals, tree, NewChannel(), opts)
+		assert.Len(t, layers, 2)
+		assert.Len(t, trees, 1)
+	})
+	t.Run("TestCappedLayers", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.CapHeight = 2
+		proof := proveFRI(opts, 16, coeffs...)
+		assert.NoError(t, verifyFRI(proof, opts, 16))
+
+		// the verifier expects caps of the configured height
+		opts.CapHeight = 0
+		assert.Error(t, verifyFRI(proof, opts, 16))
+		opts.CapHeight = 3
+		assert.Error(t, verifyFRI(proof, opts, 16))
+	})
+	t.Run("TestRejectHighDegree", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.FoldingFactor = 4
+		proof := proveFRI(opts, 16, append(coeffs, 17)...)
+		assert.Error(t, verifyFRI(proof, opts, 16))
+
+		opts.RemainderDegreeBound = 4
+		proof = proveFRI(opts, 16, append(coeffs, 17)...)
+		assert.Error(t, verifyFRI(proof, opts, 16))
+	})
+	t.Run("TestRejectTamperedProof", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.FoldingFactor, opts.RemainderDegreeBound = 4, 2
+		for i, msg := range proveFRI(opts, 16, coeffs...).Messages {
+			if msg.Label != "fri.coset" && msg.Label != "fri.remainder" {
+				continue
+			}
+			proof := proveFRI(opts, 16, coeffs...)
+			proof.Messages[i].Data = append([]byte(nil), msg.Data...)
+			proof.Messages[i].Data[len(msg.Data)-1] ^= 1
+			assert.Error(t, verifyFRI(proof, opts, 16), "message %d", i)
+		}
+	})
+	t.Run("TestSingleLeafPerLayer", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.FoldingFactor = 4
+		proof := proveFRI(opts, 16, coeffs...)
+		cosets, proofs := 0, 0
+		for _, msg := range proof.Messages {
+			switch msg.Label {
+			case "fri.coset":
+				cosets++
+				assert.Len(t, msg.Data, 4*opts.FoldingFactor)
+			case "fri.multiproof":
+				proofs++
+			}
+		}
+		// a leaf per query and per committed layer
+		assert.Equal(t, 4*proofs, cosets)
+	})
+}
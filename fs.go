@@ -1,11 +1,10 @@
 package zkstarks
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 	"math/big"
 	"strings"
-
-	"golang.org/x/crypto/sha3"
 )
 
 // This file contains an implementation of the Fiat-Shamir heuristic channel
@@ -19,74 +18,272 @@ import (
 // use to simulate randomness when emulating prover-verifier interaction.
 // More : https://merlin.cool/
 
+// The hash function used to update the state is chosen when the channel
+// is created and recorded as the first entry of the proof (the header).
+
+// Every operation is labelled with the protocol step it belongs to and
+// hashed with a domain separation tag :
+// - Send(label,m) sets the state to H(sendTag || len(label) || label || len(m) || m || state)
+// - challenges are read from the stream H(challengeTag || len(label) || label || counter || state)
+// for counter = 0,1,2... then the state is set to
+// H(ratchetTag || len(label) || label || counter || state).
+// Random integers are drawn by rejection sampling : candidates are read from
+// the stream with as many bits as the range and discarded if they are
+// out of range, the accepted value is uniform unlike a modular reduction.
+// Many challenges can be drawn from the same state with a single ratchet.
+
+// Any transcript implementing the FiatShamir interface can replace the
+// channel, see merlin.go for an implementation based on STROBE.
+
 var (
+	hashHeader     = "hash:"
 	sendOperator   = "send:"
 	receiveRandInt = "receiveRandInt:"
 	receiveRandFE  = "receiveRandFE:"
+	receiveBytes   = "receiveBytes:"
 )
 
+var (
+	sendTag      = []byte("zkstarks/send")
+	challengeTag = []byte("zkstarks/challenge")
+	ratchetTag   = []byte("zkstarks/ratchet")
+)
+
+// FiatShamir represents a transcript absorbing labelled prover messages
+// and deriving verifier challenges from them.
+type FiatShamir interface {
+	// AppendMessage absorbs a prover message
+	AppendMessage(label string, data []byte)
+	// ChallengeBytes derives n challenge bytes
+	ChallengeBytes(label string, n int) []byte
+	// Send is an alias of AppendMessage
+	Send(label string, data []byte)
+	// RandInt derives a uniform integer in [min,max]
+	RandInt(label string, min, max *big.Int) *big.Int
+	// RandInts derives n uniform integers in [min,max]
+	RandInts(label string, n int, min, max *big.Int) []*big.Int
+	// RandFE derives a uniform field element
+	RandFE(label string, m *big.Int) *big.Int
+	// RandFEs derives n uniform field elements
+	RandFEs(label string, n int, m *big.Int) []*big.Int
+}
+
 // Channel represents a FS transcript cache
 type Channel struct {
-	State []byte
-	Proof []string
+	State  []byte
+	Proof  []string
+	Trace  []TraceEntry
+	hasher Hasher
+	debug  bool
 }
 
-// NewChannel creates a new instance of the FS channel
+// NewChannel creates a new instance of the FS channel using SHA3-256
 func NewChannel() *Channel {
-	return &Channel{
-		State: []byte{0},
-		Proof: make([]string, 0, 64),
+	return NewChannelWithHasher(NewSHA3Hasher())
+}
+
+// NewChannelWithHasher creates a new instance of the FS channel using
+// the given hash function.
+func NewChannelWithHasher(h Hasher) *Channel {
+	ch := &Channel{
+		State:  []byte{0},
+		Proof:  make([]string, 0, 64),
+		hasher: h,
 	}
+	ch.Proof = append(ch.Proof, hashHeader+h.Name())
+	return ch
 }
 
-// Send appends items to the channel state by hashing them
-func (ch *Channel) Send(s []byte) {
-	var builder strings.Builder
-	builder.WriteString(sendOperator)
-	builder.WriteString(hex.EncodeToString(s))
+// Hasher returns the hash function used by the channel
+func (ch *Channel) Hasher() Hasher {
+	if ch.hasher == nil {
+		return NewSHA3Hasher()
+	}
+	return ch.hasher
+}
+
+// EnableDebug makes the channel record its state after every operation
+// in Trace.
+func (ch *Channel) EnableDebug() {
+	ch.debug = true
+}
 
+// log appends the operation to the proof and to the trace in debug mode
+func (ch *Channel) log(operator, label, payload string) {
+
+	var builder strings.Builder
+	builder.WriteString(operator)
+	builder.WriteString(label)
+	builder.WriteString(":")
+	builder.WriteString(payload)
 	ch.Proof = append(ch.Proof, builder.String())
-	ch.State = hash(concat(ch.State, s))
+
+	if ch.debug {
+		ch.Trace = append(ch.Trace, TraceEntry{
+			Operation: strings.TrimSuffix(operator, ":"),
+			Label:     label,
+			Payload:   payload,
+			State:     hex.EncodeToString(ch.State),
+		})
+	}
+}
+
+// Send appends items to the channel state by hashing them
+func (ch *Channel) Send(label string, s []byte) {
+	ch.State = ch.Hasher().Hash(sendTag, encodeLabel(label), encodeLength(len(s)), s, ch.State)
+	ch.log(sendOperator, label, hex.EncodeToString(s))
+}
+
+// AppendMessage is an alias of Send
+func (ch *Channel) AppendMessage(label string, data []byte) {
+	ch.Send(label, data)
+}
+
+// ChallengeBytes returns n challenge bytes drawn from the current state.
+func (ch *Channel) ChallengeBytes(label string, n int) []byte {
+
+	stream := ch.challengeStream(label)
+	b := append([]byte(nil), stream.read(n)...)
+	stream.ratchet()
+	ch.log(receiveBytes, label, hex.EncodeToString(b))
+
+	return b
+}
+
+// Challenge returns n challenges of the hash function's size drawn from
+// the current state, they are the blocks of the stream read and logged as
+// ChallengeBytes does so that replaying the proof follows the state.
+func (ch *Channel) Challenge(label string, n int) [][]byte {
+
+	size := len(ch.Hasher().Hash())
+	b := ch.ChallengeBytes(label, n*size)
+	challenges := make([][]byte, n)
+	for i := range challenges {
+		challenges[i] = b[i*size : (i+1)*size]
+	}
+
+	return challenges
 }
 
 // RandInt emulates a random integer scalar in the range [min,max]
 // sent by the verifier
-func (ch *Channel) RandInt(min, max *big.Int) *big.Int {
-
-	stateAsInt := new(big.Int).SetBytes(ch.State)
-	diff := new(big.Int).Sub(max, min)
-	diff = diff.Add(diff, big.NewInt(1))
-	reduced := new(big.Int).Mod(stateAsInt, diff)
+func (ch *Channel) RandInt(label string, min, max *big.Int) *big.Int {
+	return ch.RandInts(label, 1, min, max)[0]
+}
 
-	num := new(big.Int).Add(min, reduced)
+// RandInts emulates n random integer scalars in the range [min,max]
+// sent by the verifier, all of them are drawn from the current state.
+func (ch *Channel) RandInts(label string, n int, min, max *big.Int) []*big.Int {
 
-	var builder strings.Builder
-	builder.WriteString(receiveRandInt)
-	builder.WriteString(num.String())
+	stream := ch.challengeStream(label)
+	nums := make([]*big.Int, n)
 
-	ch.Proof = append(ch.Proof, builder.String())
-	ch.State = hash(ch.State)
-	return num
+	for i := range nums {
+		nums[i] = sampleUniformInt(stream.read, min, max)
+	}
+	stream.ratchet()
+	for _, num := range nums {
+		ch.log(receiveRandInt, label, num.String())
+	}
 
+	return nums
 }
 
 // RandFE emulates a random field element sent by the verifier given the field's
 // modulus.
-func (ch *Channel) RandFE(m *big.Int) *big.Int {
+func (ch *Channel) RandFE(label string, m *big.Int) *big.Int {
+	return ch.RandFEs(label, 1, m)[0]
+}
+
+// RandFEs emulates n random field elements sent by the verifier given the
+// field's modulus, all of them are drawn from the current state.
+func (ch *Channel) RandFEs(label string, n int, m *big.Int) []*big.Int {
+
+	stream := ch.challengeStream(label)
 	max := new(big.Int).Sub(m, big.NewInt(1))
-	num := ch.RandInt(big.NewInt(0), new(big.Int).Set(max))
+	nums := make([]*big.Int, n)
 
-	var builder strings.Builder
-	builder.WriteString(receiveRandFE)
-	builder.WriteString(num.String())
+	for i := range nums {
+		nums[i] = sampleUniformInt(stream.read, big.NewInt(0), max)
+	}
+	stream.ratchet()
+	for _, num := range nums {
+		ch.log(receiveRandFE, label, num.String())
+	}
+
+	return nums
+}
+
+// challengeStream reads challenge bytes for a label from the channel state
+type challengeStream struct {
+	ch      *Channel
+	label   []byte
+	counter uint64
+	buf     []byte
+}
 
-	return num
+func (ch *Channel) challengeStream(label string) *challengeStream {
+	return &challengeStream{
+		ch:    ch,
+		label: encodeLabel(label),
+	}
+}
 
+// block returns the next output of the stream
+func (s *challengeStream) block() []byte {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], s.counter)
+	s.counter++
+	return s.ch.Hasher().Hash(challengeTag, s.label, counter[:], s.ch.State)
 }
-func concat(a, b []byte) []byte {
-	return append(a, b...)
+
+// read returns the next n bytes of the stream
+func (s *challengeStream) read(n int) []byte {
+	for len(s.buf) < n {
+		s.buf = append(s.buf, s.block()...)
+	}
+	b := s.buf[:n]
+	s.buf = s.buf[n:]
+	return b
 }
-func hash(b []byte) []byte {
-	h := sha3.Sum256(b)
-	return h[:]
+
+// sampleUniformInt samples an integer in [min,max] by rejection sampling
+// candidates are read from the given source of challenge bytes.
+func sampleUniformInt(read func(n int) []byte, min, max *big.Int) *big.Int {
+
+	diff := new(big.Int).Sub(max, min)
+	bits := diff.BitLen()
+	if bits == 0 {
+		return new(big.Int).Set(min)
+	}
+	size := (bits + 7) / 8
+	excess := uint(size*8 - bits)
+
+	for {
+		b := append([]byte(nil), read(size)...)
+		b[0] &= 0xff >> excess
+		candidate := new(big.Int).SetBytes(b)
+		if candidate.Cmp(diff) <= 0 {
+			return candidate.Add(candidate, min)
+		}
+	}
+}
+
+// ratchet updates the channel state once the challenges are drawn
+func (s *challengeStream) ratchet() {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], s.counter)
+	s.ch.State = s.ch.Hasher().Hash(ratchetTag, s.label, counter[:], s.ch.State)
+}
+
+// encodeLabel returns the length prefixed label
+func encodeLabel(label string) []byte {
+	return append(encodeLength(len(label)), label...)
+}
+
+// encodeLength returns the length as a big endian 64 bits integer
+func encodeLength(n int) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(n))
+	return b[:]
 }
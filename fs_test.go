@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/actuallyachraf/algebra/nt"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestFiatShamirChannel(t *testing.T) {
@@ -16,13 +17,13 @@ func TestFiatShamirChannel(t *testing.T) {
 	t.Run("TestFiatShamirReproducible", func(t *testing.T) {
 		c := NewChannel()
 
-		c.Send([]byte("Yes"))
-		r1 := c.RandInt(nt.FromInt64(0), nt.FromInt64(math.MaxUint32))
+		c.Send("message", []byte("Yes"))
+		r1 := c.RandInt("challenge", nt.FromInt64(0), nt.FromInt64(math.MaxUint32))
 
 		d := NewChannel()
 
-		d.Send([]byte("Yes"))
-		r2 := d.RandInt(nt.FromInt64(0), nt.FromInt64(math.MaxUint32))
+		d.Send("message", []byte("Yes"))
+		r2 := d.RandInt("challenge", nt.FromInt64(0), nt.FromInt64(math.MaxUint32))
 
 		if r1.Cmp(r2) != 0 {
 			t.Fatal("error FiatShamir channel should be reproducible")
@@ -43,14 +44,14 @@ func TestFiatShamirChannel(t *testing.T) {
 		if err != nil {
 			t.Fatal("failed to write integer to buffer")
 		}
-		c.Send(intBuf.Bytes())
+		c.Send("seed", intBuf.Bytes())
 
 		dist := make([]*big.Int, 0, numTries)
 		distRand := make([]*big.Int, 0, numTries)
 
 		var i int64 = 0
 		for i = 0; i < numTries; i++ {
-			dist = append(dist, c.RandInt(nt.FromInt64(0), nt.FromInt64(rangeSize-1)))
+			dist = append(dist, c.RandInt("challenge", nt.FromInt64(0), nt.FromInt64(rangeSize-1)))
 			distRand = append(distRand, big.NewInt(rand.Int63n(rangeSize-1)))
 		}
 
@@ -78,5 +79,73 @@ func TestFiatShamirChannel(t *testing.T) {
 			t.Error("fiat shamir channel is not uniform")
 		}
 	})
+	t.Run("TestFiatShamirLabels", func(t *testing.T) {
+		c := NewChannel()
+		d := NewChannel()
+
+		c.Send("commitment", []byte("Yes"))
+		d.Send("evaluation", []byte("Yes"))
+		if bytes.Equal(c.State, d.State) {
+			t.Fatal("error messages with different labels should give different states")
+		}
+
+		c = NewChannel()
+		d = NewChannel()
+		r1 := c.RandInt("beta", nt.FromInt64(0), nt.FromInt64(math.MaxUint32))
+		r2 := d.RandInt("query", nt.FromInt64(0), nt.FromInt64(math.MaxUint32))
+		if r1.Cmp(r2) == 0 {
+			t.Fatal("error challenges with different labels should be different")
+		}
+		assert.Equal(t, "receiveRandInt:beta:"+r1.String(), c.Proof[len(c.Proof)-1])
+	})
+	t.Run("TestFiatShamirRejectionSampling", func(t *testing.T) {
+		c := NewChannel()
+		c.Send("seed", []byte("Yes"))
+
+		// [5,10] has 6 elements, a 3 bits candidate is rejected
+		// with probability 1/4 and every element should be hit
+		counts := make(map[int64]int)
+		for i := 0; i < 600; i++ {
+			r := c.RandInt("challenge", nt.FromInt64(5), nt.FromInt64(10))
+			if r.Cmp(nt.FromInt64(5)) < 0 || r.Cmp(nt.FromInt64(10)) > 0 {
+				t.Fatal("error random integer out of range :", r)
+			}
+			counts[r.Int64()]++
+		}
+		assert.Len(t, counts, 6)
+		for _, count := range counts {
+			assert.True(t, count > 50)
+		}
 
+		fe := c.RandFE("element", PrimeField.Modulus())
+		assert.True(t, fe.Cmp(PrimeField.Modulus()) < 0)
+		assert.Equal(t, int64(7), c.RandInt("constant", nt.FromInt64(7), nt.FromInt64(7)).Int64())
+	})
+	t.Run("TestFiatShamirManyChallenges", func(t *testing.T) {
+		c := NewChannel()
+		d := NewChannel()
+		c.Send("seed", []byte("Yes"))
+		d.Send("seed", []byte("Yes"))
+
+		many := c.RandFEs("coefficients", 3, PrimeField.Modulus())
+		first := d.RandFE("coefficients", PrimeField.Modulus())
+		assert.Equal(t, first, many[0])
+		assert.NotEqual(t, many[0], many[1])
+		assert.NotEqual(t, many[1], many[2])
+		assert.Len(t, c.Proof, 5)
+
+		challenges := c.Challenge("bytes", 2)
+		assert.Len(t, challenges, 2)
+		assert.NotEqual(t, challenges[0], challenges[1])
+		assert.Len(t, c.Proof, 6)
+
+		// the challenges are logged and replayed as challenge bytes
+		e := NewChannel()
+		e.Send("seed", []byte("Yes"))
+		e.RandFEs("coefficients", 3, PrimeField.Modulus())
+		b := e.ChallengeBytes("bytes", 2*len(challenges[0]))
+		assert.Equal(t, append(append([]byte(nil), challenges[0]...), challenges[1]...), b)
+		assert.Equal(t, c.State, e.State)
+		assert.Equal(t, c.Proof, e.Proof)
+	})
 }
@@ -0,0 +1,126 @@
+package zkstarks
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"math/bits"
+)
+
+// Query indices are drawn from the channel state once every commitment is
+// sent, a cheating prover can retry commitments until the queries miss the
+// positions it cheated on, each retry costs as much as hashing a layer.
+// Grinding makes each retry more expensive : before the queries are drawn
+// the prover searches for a nonce such that H(grindTag || state || nonce)
+// has k leading zero bits and sends it, the verifier checks the nonce
+// against the state it has replayed before deriving the queries.
+// A cheating prover now has to do 2^k hashes per retry which adds k bits
+// of security without adding queries.
+
+var grindTag = []byte("zkstarks/grind")
+
+// Grind searches for a nonce whose hash with the channel state has the
+// given number of leading zero bits and sends it.
+func Grind(channel *Channel, grindingBits int) uint64 {
+	return grind(channelTranscript(channel), grindingBits)
+}
+
+// grind searches for the nonce on a prover transcript
+func grind(tr Transcript, grindingBits int) uint64 {
+
+	var nonce uint64
+	for !VerifyGrinding(tr.Hasher(), tr.State(), nonce, grindingBits) {
+		nonce++
+	}
+	proverMessage(tr, "grinding.nonce", encodeNonce(tr.Hasher(), nonce))
+
+	return nonce
+}
+
+// readGrinding reads the nonce from a verifier transcript and checks its
+// proof of work against the state before it.
+func readGrinding(tr Transcript, grindingBits int) error {
+
+	state := append([]byte(nil), tr.State()...)
+	b, err := tr.Message("grinding.nonce", nil)
+	if err != nil {
+		return err
+	}
+	nonce, err := decodeNonce(tr.Hasher(), b)
+	if err != nil {
+		return err
+	}
+	if !VerifyGrinding(tr.Hasher(), state, nonce, grindingBits) {
+		return errors.New("bad grinding nonce")
+	}
+	return nil
+}
+
+// VerifyGrinding checks the proof of work of the nonce for the given state
+func VerifyGrinding(hasher Hasher, state []byte, nonce uint64, grindingBits int) bool {
+	digest := hasher.Hash(grindTag, state, encodeNonce(hasher, nonce))
+	return leadingZeroBits(digest) >= grindingBits
+}
+
+// FRIDecommitWithOptions grinds if required, draws the query indices
+// from the channel and decommits on them.
+func FRIDecommitWithOptions(channel *Channel, cosetTree *MerkleTree, friTrees []*MerkleTree, opts ProofOptions) {
+
+	requireFRI(opts)
+	if opts.GrindingBits > 0 {
+		Grind(channel, opts.GrindingBits)
+	}
+
+	// each query opens the cosets at index, index+8 and index+16
+	lb := big.NewInt(0)
+	ub := big.NewInt(int64(cosetTree.Size() - 17))
+
+	indices := make([]int, opts.NumQueries)
+	for i, randIdx := range channel.RandInts("query.index", len(indices), lb, ub) {
+		indices[i] = int(randIdx.Int64())
+	}
+
+	DecommitOnQueries(indices, channel, cosetTree, friTrees, opts.FoldingFactor)
+}
+
+// ParseNonce decodes a nonce sent by Grind
+func ParseNonce(b []byte) (uint64, error) {
+	if len(b) != 8 {
+		return 0, errors.New("bad nonce encoding")
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// encodeNonce encodes the nonce as 8 bytes big endian or as a uint256 word
+// for Keccak-256 transcripts
+func encodeNonce(hasher Hasher, nonce uint64) []byte {
+	if hasher.Name() == keccakName {
+		return EVMWord(new(big.Int).SetUint64(nonce))
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], nonce)
+	return b[:]
+}
+
+// decodeNonce parses a nonce encoded by encodeNonce
+func decodeNonce(hasher Hasher, b []byte) (uint64, error) {
+	if hasher.Name() != keccakName {
+		return ParseNonce(b)
+	}
+	if len(b) != 32 || new(big.Int).SetBytes(b[:24]).Sign() != 0 {
+		return 0, errors.New("bad nonce encoding")
+	}
+	return binary.BigEndian.Uint64(b[24:]), nil
+}
+
+// leadingZeroBits returns the number of leading zero bits of the digest
+func leadingZeroBits(digest []byte) int {
+	count := 0
+	for _, b := range digest {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+	return count
+}
@@ -0,0 +1,60 @@
+package zkstarks
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/actuallyachraf/algebra/nt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrinding(t *testing.T) {
+
+	t.Run("TestLeadingZeroBits", func(t *testing.T) {
+		assert.Equal(t, 0, leadingZeroBits([]byte{0x80, 0}))
+		assert.Equal(t, 7, leadingZeroBits([]byte{0x01, 0xff}))
+		assert.Equal(t, 12, leadingZeroBits([]byte{0x00, 0x0f}))
+		assert.Equal(t, 16, leadingZeroBits([]byte{0x00, 0x00}))
+	})
+	t.Run("TestGrindAndVerify", func(t *testing.T) {
+		channel := NewChannel()
+		channel.Send("fri.commitment", []byte("root"))
+		state := append([]byte(nil), channel.State...)
+
+		nonce := Grind(channel, 10)
+		assert.True(t, VerifyGrinding(channel.Hasher(), state, nonce, 10))
+		assert.Equal(t, "send:grinding.nonce:"+hex.EncodeToString(encodeNonce(channel.Hasher(), nonce)), channel.Proof[len(channel.Proof)-1])
+
+		sent, err := ParseNonce(encodeNonce(channel.Hasher(), nonce))
+		assert.NoError(t, err)
+		assert.Equal(t, nonce, sent)
+		_, err = ParseNonce([]byte{1, 2})
+		assert.Error(t, err)
+
+		// the nonce is bound to the state it was ground on
+		for i := uint64(0); i < nonce; i++ {
+			assert.False(t, VerifyGrinding(channel.Hasher(), state, i, 10))
+		}
+	})
+	t.Run("TestGrindingChangesQueries", func(t *testing.T) {
+		withoutGrinding := NewChannel()
+		withGrinding := NewChannel()
+		Grind(withGrinding, 4)
+
+		lb, ub := nt.FromInt64(0), nt.FromInt64(8196-16)
+		a := withoutGrinding.RandInt("query.index", lb, ub)
+		b := withGrinding.RandInt("query.index", lb, ub)
+		assert.NotEqual(t, a, b)
+	})
+	t.Run("TestQueriesStayInTree", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.NumQueries = 64
+		_, domain, evals, tree := testFRIInstance(64, 1, 2, 3, 4)
+		_, _, friTrees := GenerateFRICommitmentWithOptions(4, domain, evals, tree, NewChannel(), opts)
+
+		// every drawn index opens index+16 inside the 32 leaf tree
+		assert.NotPanics(t, func() { FRIDecommitWithOptions(NewChannel(), tree, friTrees, opts) })
+		assert.NotPanics(t, func() { DecommitOnQueries([]int{tree.Size() - 17}, NewChannel(), tree, friTrees, 2) })
+		assert.Panics(t, func() { DecommitOnQueries([]int{tree.Size() - 16}, NewChannel(), tree, friTrees, 2) })
+	})
+}
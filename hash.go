@@ -0,0 +1,112 @@
+package zkstarks
+
+import (
+	"crypto/sha256"
+
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/sha3"
+	"lukechampine.com/blake3"
+)
+
+// Merkle commitments and the Fiat-Shamir channel both reduce to calls
+// to a hash function, the Hasher interface lets the prover pick which one.
+// The hasher's name is recorded in the proof so that a verifier knows
+// which hash function to replay it with.
+
+// Hasher represents a hash function used for commitments and transcripts
+type Hasher interface {
+	// Name identifies the hash function in the proof header
+	Name() string
+	// Hash returns the digest of the concatenation of the inputs
+	Hash(data ...[]byte) []byte
+}
+
+type sha3Hasher struct{}
+
+// NewSHA3Hasher returns a SHA3-256 hasher
+func NewSHA3Hasher() Hasher {
+	return sha3Hasher{}
+}
+
+func (sha3Hasher) Name() string {
+	return "sha3-256"
+}
+
+func (sha3Hasher) Hash(data ...[]byte) []byte {
+	h := sha3.New256()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+type sha256Hasher struct{}
+
+// NewSHA256Hasher returns a SHA-256 hasher
+func NewSHA256Hasher() Hasher {
+	return sha256Hasher{}
+}
+
+func (sha256Hasher) Name() string {
+	return "sha-256"
+}
+
+func (sha256Hasher) Hash(data ...[]byte) []byte {
+	h := sha256.New()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+type blake2sHasher struct{}
+
+// NewBlake2sHasher returns a BLAKE2s-256 hasher
+func NewBlake2sHasher() Hasher {
+	return blake2sHasher{}
+}
+
+func (blake2sHasher) Name() string {
+	return "blake2s-256"
+}
+
+func (blake2sHasher) Hash(data ...[]byte) []byte {
+	// New256 only fails for keys longer than 32 bytes
+	h, _ := blake2s.New256(nil)
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+type blake3Hasher struct{}
+
+// NewBlake3Hasher returns a BLAKE3-256 hasher
+func NewBlake3Hasher() Hasher {
+	return blake3Hasher{}
+}
+
+func (blake3Hasher) Name() string {
+	return "blake3-256"
+}
+
+func (blake3Hasher) Hash(data ...[]byte) []byte {
+	h := blake3.New(32, nil)
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+// HasherFromName returns the hasher recorded under the given name
+// algebraic hashers are named after the hash function and the modulus
+// of the field they are instantiated over.
+func HasherFromName(name string) (Hasher, error) {
+
+	for _, h := range []Hasher{NewSHA3Hasher(), NewSHA256Hasher(), NewBlake2sHasher(), NewBlake3Hasher(), NewKeccakHasher()} {
+		if h.Name() == name {
+			return h, nil
+		}
+	}
+	return algebraicHasherFromName(name)
+}
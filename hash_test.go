@@ -0,0 +1,50 @@
+package zkstarks
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashers(t *testing.T) {
+
+	t.Run("TestHasherVectors", func(t *testing.T) {
+		vectors := map[string]string{
+			"sha3-256":    "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532",
+			"sha-256":     "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+			"blake2s-256": "508c5e8c327c14e2e1a72ba34eeb452f37458b209ed63a294d999b4c86675982",
+			"blake3-256":  "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85",
+		}
+		for name, digest := range vectors {
+			h, err := HasherFromName(name)
+			assert.NoError(t, err)
+			assert.Equal(t, name, h.Name())
+			assert.Equal(t, digest, hex.EncodeToString(h.Hash([]byte("abc"))))
+			assert.Equal(t, digest, hex.EncodeToString(h.Hash([]byte("a"), []byte("bc"))))
+		}
+		_, err := HasherFromName("md5")
+		assert.Error(t, err)
+	})
+	t.Run("TestMerkleTreeHasher", func(t *testing.T) {
+		leaves := [][]byte{{1}, {2}, {3}, {4}}
+		tree := NewMerkleTreeWithHasher(leaves, 0, NewBlake3Hasher())
+		assert.NotEqual(t, NewMerkleTree(leaves).Root(), tree.Root())
+
+		auditPath, err := tree.Open(2)
+		assert.NoError(t, err)
+		assert.True(t, VerifyMerkleProof(NewBlake3Hasher(), tree.Root(), leaves[2], auditPath))
+		assert.False(t, VerifyMerkleProof(NewSHA256Hasher(), tree.Root(), leaves[2], auditPath))
+	})
+	t.Run("TestChannelHasher", func(t *testing.T) {
+		c := NewChannelWithHasher(NewBlake2sHasher())
+		d := NewChannel()
+		c.Send("message", []byte("Yes"))
+		d.Send("message", []byte("Yes"))
+
+		assert.Equal(t, "hash:blake2s-256", c.Proof[0])
+		assert.Equal(t, "hash:sha3-256", d.Proof[0])
+		assert.NotEqual(t, c.State, d.State)
+		assert.Equal(t, NewBlake2sHasher().Hash(sendTag, encodeLabel("message"), encodeLength(3), []byte("Yes"), []byte{0}), c.State)
+	})
+}
@@ -0,0 +1,261 @@
+package zkstarks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"golang.org/x/crypto/sha3"
+)
+
+// Verifying proofs on-chain requires a transcript that an EVM contract
+// can replay cheaply, the EVM exposes Keccak-256 (not SHA3-256) and works
+// on 32 bytes big endian words.
+// KeccakChannel follows that convention :
+// - integers and field elements are encoded as uint256 words i.e 32 bytes big endian
+// - labels are absorbed as keccak256(label) which a contract stores as constants
+// - the state is a 32 bytes digest initialized to keccak256(protocol label)
+// - Send(label,m) sets state = keccak256(state || keccak256(label) || m)
+// and resets the challenge counter to 0
+// - challenge words are keccak256(state || keccak256(label) || uint256(counter))
+// with the counter incremented after each word, the state isn't changed by
+// challenges so a contract only keeps (state,counter) in memory
+// - a challenge in [0,r) is drawn by rejection sampling : a word w is
+// accepted if w < 2^256 - (2^256 mod r) and the challenge is w mod r.
+// In Solidity a send reads keccak256(abi.encodePacked(state, LABEL, m)) and
+// a challenge keccak256(abi.encodePacked(state, LABEL, counter)).
+// KeccakChannel is a prover Transcript so FRI, grinding and the other
+// protocols run on it unchanged, a verifier replays its proof with
+// NewKeccakVerifierTranscript. Keccak-256 transcripts and Merkle trees encode
+// every field element and the grinding nonce as uint256 words.
+
+const keccakName = "keccak-256"
+
+var evmWordBound = new(big.Int).Lsh(big.NewInt(1), 256)
+
+type keccakHasher struct{}
+
+// NewKeccakHasher returns a Keccak-256 hasher matching the EVM's keccak256
+func NewKeccakHasher() Hasher {
+	return keccakHasher{}
+}
+
+func (keccakHasher) Name() string {
+	return keccakName
+}
+
+func (keccakHasher) Hash(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+// KeccakChannel represents an EVM compatible FS transcript
+type KeccakChannel struct {
+	Counter uint64
+	Proof   []string
+	state   []byte
+}
+
+// NewKeccakChannel creates a new EVM compatible channel for the protocol
+// with the given label.
+func NewKeccakChannel(label string) *KeccakChannel {
+	return &KeccakChannel{
+		Proof: []string{hashHeader + keccakName},
+		state: keccak256([]byte(label)),
+	}
+}
+
+// Send absorbs a prover message
+func (ch *KeccakChannel) Send(label string, data []byte) {
+	var builder strings.Builder
+	builder.WriteString(sendOperator)
+	builder.WriteString(label)
+	builder.WriteString(":")
+	builder.WriteString(hex.EncodeToString(data))
+
+	ch.Proof = append(ch.Proof, builder.String())
+	ch.state = keccak256(ch.state, keccak256([]byte(label)), data)
+	ch.Counter = 0
+}
+
+// Message absorbs and records a prover message
+func (ch *KeccakChannel) Message(label string, data []byte) ([]byte, error) {
+	ch.Send(label, data)
+	return data, nil
+}
+
+// State returns the state challenges are derived from
+func (ch *KeccakChannel) State() []byte {
+	return ch.state
+}
+
+// Hasher returns the Keccak-256 hasher
+func (ch *KeccakChannel) Hasher() Hasher {
+	return NewKeccakHasher()
+}
+
+// AppendMessage is an alias of Send
+func (ch *KeccakChannel) AppendMessage(label string, data []byte) {
+	ch.Send(label, data)
+}
+
+// SendFieldElements absorbs field elements encoded as uint256 words
+func (ch *KeccakChannel) SendFieldElements(label string, elems []ff.FieldElement) {
+	ch.Send(label, bytes.Join(DomainWords(elems), nil))
+}
+
+// ChallengeBytes returns n challenge bytes read from consecutive words
+func (ch *KeccakChannel) ChallengeBytes(label string, n int) []byte {
+
+	b := make([]byte, 0, n+32)
+	for len(b) < n {
+		b = append(b, ch.challengeWord(label)...)
+	}
+	b = b[:n]
+
+	var builder strings.Builder
+	builder.WriteString(receiveBytes)
+	builder.WriteString(label)
+	builder.WriteString(":")
+	builder.WriteString(hex.EncodeToString(b))
+	ch.Proof = append(ch.Proof, builder.String())
+
+	return b
+}
+
+// RandInt derives a uniform integer in [min,max]
+func (ch *KeccakChannel) RandInt(label string, min, max *big.Int) *big.Int {
+	return ch.RandInts(label, 1, min, max)[0]
+}
+
+// RandInts derives n uniform integers in [min,max]
+func (ch *KeccakChannel) RandInts(label string, n int, min, max *big.Int) []*big.Int {
+
+	nums := make([]*big.Int, n)
+	for i := range nums {
+		nums[i] = ch.uniformInt(label, min, max)
+
+		var builder strings.Builder
+		builder.WriteString(receiveRandInt)
+		builder.WriteString(label)
+		builder.WriteString(":")
+		builder.WriteString(nums[i].String())
+		ch.Proof = append(ch.Proof, builder.String())
+	}
+	return nums
+}
+
+// RandFE derives a uniform field element given the field's modulus
+func (ch *KeccakChannel) RandFE(label string, m *big.Int) *big.Int {
+	return ch.RandFEs(label, 1, m)[0]
+}
+
+// RandFEs derives n uniform field elements given the field's modulus
+func (ch *KeccakChannel) RandFEs(label string, n int, m *big.Int) []*big.Int {
+
+	max := new(big.Int).Sub(m, big.NewInt(1))
+	nums := make([]*big.Int, n)
+	for i := range nums {
+		nums[i] = ch.uniformInt(label, big.NewInt(0), max)
+
+		var builder strings.Builder
+		builder.WriteString(receiveRandFE)
+		builder.WriteString(label)
+		builder.WriteString(":")
+		builder.WriteString(nums[i].String())
+		ch.Proof = append(ch.Proof, builder.String())
+	}
+	return nums
+}
+
+// challengeWord returns the next challenge word and increments the counter
+func (ch *KeccakChannel) challengeWord(label string) []byte {
+	var counter [32]byte
+	binary.BigEndian.PutUint64(counter[24:], ch.Counter)
+	ch.Counter++
+	return keccak256(ch.state, keccak256([]byte(label)), counter[:])
+}
+
+// uniformInt samples an integer in [min,max] from challenge words
+func (ch *KeccakChannel) uniformInt(label string, min, max *big.Int) *big.Int {
+
+	r := new(big.Int).Sub(max, min)
+	r.Add(r, big.NewInt(1))
+	bound := new(big.Int).Sub(evmWordBound, new(big.Int).Mod(evmWordBound, r))
+
+	for {
+		w := new(big.Int).SetBytes(ch.challengeWord(label))
+		if w.Cmp(bound) < 0 {
+			return w.Mod(w, r).Add(w, min)
+		}
+	}
+}
+
+// EVMWord encodes an integer as a 32 bytes big endian word
+func EVMWord(x *big.Int) []byte {
+	word := make([]byte, 32)
+	b := x.Bytes()
+	copy(word[32-len(b):], b)
+	return word
+}
+
+// DomainWords returns the domain elements encoded as uint256 words, one
+// word per element so that each can be used as a leaf.
+func DomainWords(domain []ff.FieldElement) [][]byte {
+
+	words := make([][]byte, len(domain))
+	for i, elem := range domain {
+		words[i] = EVMWord(elem.Big())
+	}
+	return words
+}
+
+// KeccakVerifierTranscript reads the prover's messages from a proof recorded
+// by a KeccakChannel and replays them on a channel of the same protocol.
+type KeccakVerifierTranscript struct {
+	*KeccakChannel
+	proof *Proof
+	next  int
+}
+
+// NewKeccakVerifierTranscript creates a verifier transcript for the protocol
+// with the given label over a proof recorded by a KeccakChannel.
+func NewKeccakVerifierTranscript(label string, proof *Proof) (*KeccakVerifierTranscript, error) {
+
+	if proof.Hash != keccakName {
+		return nil, errors.New("proof wasn't recorded by a keccak channel")
+	}
+	return &KeccakVerifierTranscript{
+		KeccakChannel: NewKeccakChannel(label),
+		proof:         proof,
+	}, nil
+}
+
+// Message reads and absorbs the next message of the proof which must
+// carry the given label.
+func (tr *KeccakVerifierTranscript) Message(label string, _ []byte) ([]byte, error) {
+
+	data, err := readProofMessage(tr.proof, &tr.next, label)
+	if err != nil {
+		return nil, err
+	}
+	tr.Send(label, data)
+
+	return data, nil
+}
+
+// Finalize checks that every message of the proof was read
+func (tr *KeccakVerifierTranscript) Finalize() error {
+	return finalizeProof(tr.proof, tr.next)
+}
+
+func keccak256(data ...[]byte) []byte {
+	return keccakHasher{}.Hash(data...)
+}
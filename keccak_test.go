@@ -0,0 +1,162 @@
+package zkstarks
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"github.com/actuallyachraf/algebra/nt"
+	"github.com/actuallyachraf/algebra/poly"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeccakChannel(t *testing.T) {
+
+	t.Run("TestKeccakHasher", func(t *testing.T) {
+		h, err := HasherFromName("keccak-256")
+		assert.NoError(t, err)
+		assert.Equal(t, "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470", hex.EncodeToString(h.Hash()))
+		assert.Equal(t, "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45", hex.EncodeToString(h.Hash([]byte("abc"))))
+
+		leaves := [][]byte{{1}, {2}, {3}, {4}}
+		tree := NewMerkleTreeWithHasher(leaves, 0, h)
+		auditPath, err := tree.Open(1)
+		assert.NoError(t, err)
+		assert.True(t, VerifyMerkleProof(h, tree.Root(), leaves[1], auditPath))
+	})
+	t.Run("TestEVMEncoding", func(t *testing.T) {
+		word := EVMWord(big.NewInt(258))
+		assert.Equal(t, 32, len(word))
+		assert.Equal(t, byte(1), word[30])
+		assert.Equal(t, byte(2), word[31])
+
+		domain := []ff.FieldElement{PrimeField.NewFieldElementFromInt64(3), PrimeField.NewFieldElementFromInt64(5)}
+		words := DomainWords(domain)
+		assert.Len(t, words, 2)
+		assert.Equal(t, EVMWord(big.NewInt(3)), words[0])
+		assert.Equal(t, EVMWord(big.NewInt(5)), words[1])
+	})
+	t.Run("TestKnownAnswers", func(t *testing.T) {
+		// vectors computed with an independent Keccak-256 implementation
+		// following the convention of the contract
+		ch := NewKeccakChannel("zkstarks")
+		assert.Equal(t, "hash:keccak-256", ch.Proof[0])
+		assert.Equal(t, "71b1895ba123f81365d81c59ffaeed078e8dd512a2a0f5e6b96b5fefc6c4b5f8", hex.EncodeToString(ch.State()))
+
+		ch.Send("trace.commitment", NewKeccakHasher().Hash([]byte("root")))
+		assert.Equal(t, "f31d361cd150fb6a7f926d74edcdf5b180baa80980076f4292b736f0a47a799c", hex.EncodeToString(ch.State()))
+
+		beta := ch.RandFE("fri.beta", PrimeField.Modulus())
+		assert.Equal(t, int64(2258673001), beta.Int64())
+		assert.Equal(t, "receiveRandFE:fri.beta:2258673001", ch.Proof[2])
+		assert.Equal(t, "f31d361cd150fb6a7f926d74edcdf5b180baa80980076f4292b736f0a47a799c", hex.EncodeToString(ch.State()))
+
+		challenge := ch.ChallengeBytes("challenge", 40)
+		assert.Equal(t, "d7fb914b6205d222d48a32d58690e4e7c0a666c8f0b199df77dffb3e2bcaa433d5029eab0a0771d6", hex.EncodeToString(challenge))
+
+		// leaves hold the bit-reversed blocks (1,3) and (2,4) as uint256 words
+		evals := []ff.FieldElement{
+			PrimeField.NewFieldElementFromInt64(1),
+			PrimeField.NewFieldElementFromInt64(2),
+			PrimeField.NewFieldElementFromInt64(3),
+			PrimeField.NewFieldElementFromInt64(4),
+		}
+		tree := CommitFRILayer(evals, 2, 0, NewKeccakHasher())
+		assert.Equal(t, append(EVMWord(big.NewInt(1)), EVMWord(big.NewInt(3))...), tree.Leaf(0))
+		assert.Equal(t, "5bd98d26770d044704c096246697b000c741bdf49c62b6c9adf3fc020ff503f1", hex.EncodeToString(tree.Root()))
+
+		// sending resets the counter and changes the challenges
+		ch.Send("fri.commitment", tree.Root())
+		assert.Equal(t, uint64(0), ch.Counter)
+		assert.NotEqual(t, beta, ch.RandFE("fri.beta", PrimeField.Modulus()))
+	})
+	t.Run("TestTranscript", func(t *testing.T) {
+		_, domain, evals, _ := testFRIInstance(64, 1, 2, 3, 4, 5, 6, 7, 8)
+		opts := DefaultProofOptions()
+		opts.GrindingBits, opts.FoldingFactor = 4, 4
+
+		var tr Transcript = NewKeccakChannel("zkstarks")
+		commitment := FRIProve(evals, domain, 8, tr, opts)
+		ch := tr.(*KeccakChannel)
+		proof, err := ParseProofLog(ch.Proof)
+		assert.NoError(t, err)
+		for _, msg := range proof.Messages {
+			if msg.Label == "fri.coset" {
+				assert.Len(t, msg.Data, 32*opts.FoldingFactor)
+			}
+		}
+
+		verifier, err := NewKeccakVerifierTranscript("zkstarks", proof)
+		assert.NoError(t, err)
+		assert.NoError(t, FRIVerify(commitment, domain, 8, verifier, opts))
+		assert.NoError(t, verifier.Finalize())
+
+		// the protocol label is part of the transcript
+		verifier, err = NewKeccakVerifierTranscript("other", proof)
+		assert.NoError(t, err)
+		assert.Error(t, FRIVerify(commitment, domain, 8, verifier, opts))
+
+		_, err = NewKeccakVerifierTranscript("zkstarks", &Proof{Hash: "sha3-256"})
+		assert.Error(t, err)
+	})
+	t.Run("TestMessageEncoding", func(t *testing.T) {
+		// every field element and the nonce are sent as uint256 words, the
+		// values fit in the low 4 bytes of each word
+		assertWords := func(data []byte, n int, label string) {
+			assert.Len(t, data, 32*n, label)
+			for i := 0; i+32 <= len(data); i += 32 {
+				assert.Equal(t, make([]byte, 28), data[i:i+28], label)
+			}
+		}
+		messages := func(ch *KeccakChannel) map[string][]byte {
+			proof, err := ParseProofLog(ch.Proof)
+			assert.NoError(t, err)
+			sent := make(map[string][]byte)
+			for _, msg := range proof.Messages {
+				sent[msg.Label] = msg.Data
+			}
+			return sent
+		}
+		_, domain, evals, _ := testFRIInstance(64, 1, 2, 3, 4, 5, 6, 7, 8)
+		opts := DefaultProofOptions()
+		opts.GrindingBits, opts.NumQueries = 4, 8
+
+		ch := NewKeccakChannel("zkstarks")
+		FRIProve(evals, domain, 8, ch, opts)
+		sent := messages(ch)
+		assertWords(sent["fri.remainder"], opts.RemainderDegreeBound, "fri.remainder")
+		assertWords(sent["grinding.nonce"], 1, "grinding.nonce")
+		nonce, err := decodeNonce(ch.Hasher(), sent["grinding.nonce"])
+		assert.NoError(t, err)
+		assert.Equal(t, EVMWord(new(big.Int).SetUint64(nonce)), sent["grinding.nonce"])
+		_, err = decodeNonce(ch.Hasher(), encodeNonce(NewSHA3Hasher(), nonce))
+		assert.Error(t, err)
+
+		h := poly.NewPolynomialInts(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12)
+		ch = NewKeccakChannel("zkstarks")
+		commitment := ProveComposition(h, 3, 4, domain, ch, opts)
+		assertWords(messages(ch)["composition.segments.ood"], 3, "composition.segments.ood")
+		proof, _ := ParseProofLog(ch.Proof)
+		verifier, err := NewKeccakVerifierTranscript("zkstarks", proof)
+		assert.NoError(t, err)
+		_, err = VerifyComposition(commitment, 3, 4, domain, verifier, opts)
+		assert.NoError(t, err)
+
+		pcs := NewPCS(domain, 16, NewKeccakHasher(), opts)
+		ch = NewKeccakChannel("zkstarks")
+		pcs.Open(pcs.Commit(h), PrimeField.NewFieldElementFromInt64(3), ch)
+		sent = messages(ch)
+		assertWords(sent["pcs.point"], 1, "pcs.point")
+		assertWords(sent["pcs.value"], 1, "pcs.value")
+		assert.Equal(t, EVMWord(big.NewInt(3)), sent["pcs.point"])
+	})
+	t.Run("TestFiatShamirInterface", func(t *testing.T) {
+		var fs FiatShamir = NewKeccakChannel("zkstarks")
+		fs.Send("commitment", []byte("Yes"))
+		r := fs.RandInts("query", 16, nt.FromInt64(3), nt.FromInt64(9))
+		for _, x := range r {
+			assert.True(t, x.Cmp(nt.FromInt64(3)) >= 0 && x.Cmp(nt.FromInt64(9)) <= 0)
+		}
+	})
+}
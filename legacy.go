@@ -0,0 +1,160 @@
+package zkstarks
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Proofs produced before labelled transcripts are logs of unlabelled
+// operations "send:<hex>" and "receiveRandInt:<n>" (field elements were
+// logged as integers as well) without a hash header.
+// The legacy channel started from the state {0} and :
+// - Send(m) set the state to sha3(state || m)
+// - RandInt(min,max) returned min + state mod (max - min + 1) and set the
+// state to sha3(state)
+// The log doesn't record the range of each random value, a value is
+// consistent if it is re-derived from the state for one of the candidate
+// ranges, by default the ranges used by the prover (field elements and
+// query indices).
+
+const legacyHashName = "legacy-sha3-256"
+
+var errLegacyEntry = errors.New("bad legacy proof entry")
+
+// LegacyRange represents a range [Min,Max] random values were drawn from
+type LegacyRange struct {
+	Min *big.Int
+	Max *big.Int
+}
+
+// DefaultLegacyRanges returns the ranges drawn from by the legacy prover
+func DefaultLegacyRanges() []LegacyRange {
+	return []LegacyRange{
+		{Min: big.NewInt(0), Max: new(big.Int).Sub(PrimeField.Modulus(), big.NewInt(1))},
+		{Min: big.NewInt(0), Max: big.NewInt(8196 - 16)},
+	}
+}
+
+// LegacyEntry represents an operation of a legacy proof, Data is set
+// for sends and Value for random integers.
+type LegacyEntry struct {
+	Data  []byte
+	Value *big.Int
+}
+
+// IsSend returns true if the entry is a prover message
+func (e LegacyEntry) IsSend() bool {
+	return e.Value == nil
+}
+
+// LegacyProof represents a parsed legacy proof
+type LegacyProof struct {
+	Entries []LegacyEntry
+}
+
+// ParseLegacyProof parses a legacy proof log
+func ParseLegacyProof(log []string) (*LegacyProof, error) {
+
+	entries := make([]LegacyEntry, 0, len(log))
+	for i, entry := range log {
+		switch {
+		case strings.HasPrefix(entry, sendOperator):
+			data, err := hex.DecodeString(strings.TrimPrefix(entry, sendOperator))
+			if err != nil {
+				return nil, fmt.Errorf("%v at entry %d", errLegacyEntry, i)
+			}
+			entries = append(entries, LegacyEntry{Data: data})
+		case strings.HasPrefix(entry, receiveRandInt):
+			value, ok := new(big.Int).SetString(strings.TrimPrefix(entry, receiveRandInt), 10)
+			if !ok || value.Sign() < 0 {
+				return nil, fmt.Errorf("%v at entry %d", errLegacyEntry, i)
+			}
+			entries = append(entries, LegacyEntry{Value: value})
+		default:
+			return nil, fmt.Errorf("%v at entry %d", errLegacyEntry, i)
+		}
+	}
+
+	return &LegacyProof{Entries: entries}, nil
+}
+
+// Verify checks the Fiat-Shamir consistency of the proof, each random value
+// must be re-derived from the sends before it for one of the given ranges.
+func (p *LegacyProof) Verify(ranges []LegacyRange) error {
+
+	state := []byte{0}
+	for i, entry := range p.Entries {
+		if entry.IsSend() {
+			state = NewSHA3Hasher().Hash(state, entry.Data)
+			continue
+		}
+
+		stateAsInt := new(big.Int).SetBytes(state)
+		consistent := false
+		for _, r := range ranges {
+			diff := new(big.Int).Sub(r.Max, r.Min)
+			diff.Add(diff, big.NewInt(1))
+			expected := new(big.Int).Mod(stateAsInt, diff)
+			if expected.Add(expected, r.Min).Cmp(entry.Value) == 0 {
+				consistent = true
+				break
+			}
+		}
+		if !consistent {
+			return fmt.Errorf("random value %s at entry %d doesn't match the transcript", entry.Value, i)
+		}
+		state = NewSHA3Hasher().Hash(state)
+	}
+
+	return nil
+}
+
+// Proof returns the prover messages as a typed proof, legacy messages are
+// unlabelled and can only be replayed with the legacy channel.
+func (p *LegacyProof) Proof() *Proof {
+
+	proof := &Proof{Hash: legacyHashName}
+	for _, entry := range p.Entries {
+		if entry.IsSend() {
+			proof.Messages = append(proof.Messages, ProofMessage{Data: entry.Data})
+		}
+	}
+	return proof
+}
+
+// ParseProofLog parses a channel proof log into a typed proof, logs without
+// a hash header are parsed and verified as legacy proofs.
+func ParseProofLog(log []string) (*Proof, error) {
+
+	if len(log) == 0 || !strings.HasPrefix(log[0], hashHeader) {
+		legacy, err := ParseLegacyProof(log)
+		if err != nil {
+			return nil, err
+		}
+		if err := legacy.Verify(DefaultLegacyRanges()); err != nil {
+			return nil, err
+		}
+		return legacy.Proof(), nil
+	}
+
+	proof := &Proof{Hash: strings.TrimPrefix(log[0], hashHeader)}
+	for i, entry := range log[1:] {
+		if !strings.HasPrefix(entry, sendOperator) {
+			continue
+		}
+		idx := strings.LastIndex(entry, ":")
+		data, err := hex.DecodeString(entry[idx+1:])
+		if err != nil || idx < len(sendOperator) {
+			return nil, fmt.Errorf("bad proof entry %d", i+1)
+		}
+		proof.Messages = append(proof.Messages, ProofMessage{
+			Label: entry[len(sendOperator):idx],
+			Data:  data,
+		})
+	}
+
+	return proof, nil
+}
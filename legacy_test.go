@@ -0,0 +1,82 @@
+package zkstarks
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readLegacyLog reads the proof logged by TestZKGen at the baseline commit
+// bab31c6 : the evaluation root, three composition coefficients, the
+// composition root and the decommitments of three queries.
+func readLegacyLog(t *testing.T) []string {
+
+	b, err := ioutil.ReadFile("testdata/legacy_proof.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Fields(string(b))
+}
+
+func TestLegacyProof(t *testing.T) {
+
+	legacyLog := readLegacyLog(t)
+
+	t.Run("TestParseAndVerify", func(t *testing.T) {
+		legacy, err := ParseLegacyProof(legacyLog)
+		assert.NoError(t, err)
+		assert.Len(t, legacy.Entries, 149)
+		assert.True(t, legacy.Entries[0].IsSend())
+		assert.Equal(t, "0855b2c68e5b61e90c53bc28f9832f4a616e076e8b5449874b19553a986244f7", hex.EncodeToString(legacy.Entries[0].Data))
+		assert.Equal(t, big.NewInt(327854267), legacy.Entries[1].Value)
+		assert.Equal(t, big.NewInt(4900), legacy.Entries[5].Value)
+		assert.NoError(t, legacy.Verify(DefaultLegacyRanges()))
+
+		proof := legacy.Proof()
+		assert.Equal(t, legacyHashName, proof.Hash)
+		assert.Len(t, proof.Messages, 143)
+		assert.Equal(t, legacy.Entries[148].Data, proof.Messages[142].Data)
+	})
+	t.Run("TestInconsistentLegacyProof", func(t *testing.T) {
+		tampered := append([]string(nil), legacyLog...)
+		tampered[6] = "send:97f5d99b"
+		legacy, err := ParseLegacyProof(tampered)
+		assert.NoError(t, err)
+		assert.Error(t, legacy.Verify(DefaultLegacyRanges()))
+
+		tampered = append([]string(nil), legacyLog...)
+		tampered[2] = "receiveRandInt:62366206"
+		_, err = ParseProofLog(tampered)
+		assert.Error(t, err)
+
+		// values outside of the candidate ranges can't be verified
+		legacy, err = ParseLegacyProof(legacyLog)
+		assert.NoError(t, err)
+		assert.Error(t, legacy.Verify(DefaultLegacyRanges()[1:]))
+	})
+	t.Run("TestMalformedLegacyProof", func(t *testing.T) {
+		for _, entry := range []string{"send:zz", "receiveRandInt:abc", "receiveRandInt:-1", "receive:1"} {
+			_, err := ParseLegacyProof([]string{entry})
+			assert.Error(t, err)
+		}
+	})
+	t.Run("TestParseProofLog", func(t *testing.T) {
+		proof, err := ParseProofLog(legacyLog)
+		assert.NoError(t, err)
+		assert.Equal(t, legacyHashName, proof.Hash)
+
+		channel := NewChannelWithHasher(NewBlake3Hasher())
+		channel.Send("trace.commitment", []byte("trace root"))
+		channel.RandFE("composition.coefficient", PrimeField.Modulus())
+		channel.Send("fri.commitment", []byte{1, 2, 3})
+
+		proof, err = ParseProofLog(channel.Proof)
+		assert.NoError(t, err)
+		assert.Equal(t, "blake3-256", proof.Hash)
+		assert.Equal(t, []ProofMessage{{"trace.commitment", []byte("trace root")}, {"fri.commitment", []byte{1, 2, 3}}}, proof.Messages)
+	})
+}
@@ -0,0 +1,251 @@
+package zkstarks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/actuallyachraf/algebra/ff"
+)
+
+// FRI on its own is a low degree test : given evaluations over a coset
+// {offset * g^i} of a subgroup of size 2^n it proves they are close to the
+// evaluations of a polynomial with less than degreeBound coefficients.
+// The prover commits to the evaluations, sends the commitment as
+// "fri.evaluations" and runs the commit and query phases on the caller's
+// transcript, query indices are drawn in the whole domain.
+// The transcript isn't finalized so both sides can keep using it once the
+// test is done.
+// FRI and STIR both implement the LowDegreeTest interface, the proof
+// options select which one NewLowDegreeTest returns.
+
+// LowDegreeTest represents a proximity test of evaluations over a coset
+// to the polynomials of bounded degree
+type LowDegreeTest interface {
+	// Name returns the name selecting the test in the proof options
+	Name() string
+	// Prove proves that the evaluations over the domain are of a polynomial
+	// with less than degreeBound coefficients and returns their commitment.
+	Prove(evals []ff.FieldElement, domain []ff.FieldElement, degreeBound int, tr Transcript) []byte
+	// Verify verifies a proof that the evaluations committed to are of a
+	// polynomial with less than degreeBound coefficients.
+	Verify(commitment []byte, domain []ff.FieldElement, degreeBound int, tr Transcript) error
+}
+
+const (
+	// FRIProximityTest selects FRI
+	FRIProximityTest = "fri"
+	// STIRProximityTest selects STIR
+	STIRProximityTest = "stir"
+)
+
+// NewLowDegreeTest returns the low degree test selected by the options
+func NewLowDegreeTest(opts ProofOptions) (LowDegreeTest, error) {
+
+	switch opts.ProximityTest {
+	case "", FRIProximityTest:
+		return friTest{opts}, nil
+	case STIRProximityTest:
+		return stirTest{opts}, nil
+	}
+	return nil, fmt.Errorf("unknown low degree test %s", opts.ProximityTest)
+}
+
+// requireFRI panics if the options select another low degree test than
+// FRI, the FRI commit and query phases can't produce its proofs.
+func requireFRI(opts ProofOptions) {
+	if opts.ProximityTest != "" && opts.ProximityTest != FRIProximityTest {
+		panic(fmt.Sprintf("FRI can't produce %s proofs, use NewLowDegreeTest", opts.ProximityTest))
+	}
+}
+
+// friTest is the FRI low degree test
+type friTest struct {
+	opts ProofOptions
+}
+
+// Name returns the name of the test
+func (friTest) Name() string {
+	return FRIProximityTest
+}
+
+// Prove runs FRIProve with the test's options
+func (f friTest) Prove(evals []ff.FieldElement, domain []ff.FieldElement, degreeBound int, tr Transcript) []byte {
+	return FRIProve(evals, domain, degreeBound, tr, f.opts)
+}
+
+// Verify runs FRIVerify with the test's options
+func (f friTest) Verify(commitment []byte, domain []ff.FieldElement, degreeBound int, tr Transcript) error {
+	return FRIVerify(commitment, domain, degreeBound, tr, f.opts)
+}
+
+// stirTest is the STIR low degree test
+type stirTest struct {
+	opts ProofOptions
+}
+
+// Name returns the name of the test
+func (stirTest) Name() string {
+	return STIRProximityTest
+}
+
+// Prove runs STIRProve with the test's options
+func (s stirTest) Prove(evals []ff.FieldElement, domain []ff.FieldElement, degreeBound int, tr Transcript) []byte {
+	return STIRProve(evals, domain, degreeBound, tr, s.opts)
+}
+
+// Verify runs STIRVerify with the test's options
+func (s stirTest) Verify(commitment []byte, domain []ff.FieldElement, degreeBound int, tr Transcript) error {
+	return STIRVerify(commitment, domain, degreeBound, tr, s.opts)
+}
+
+// FRIProve proves that the evaluations over the domain (given in its natural
+// order) are of a polynomial with less than degreeBound coefficients and
+// returns the commitment to the evaluations.
+func FRIProve(evals []ff.FieldElement, domain []ff.FieldElement, degreeBound int, tr Transcript, opts ProofOptions) []byte {
+
+	if len(evals) != len(domain) || len(domain) == 0 || len(domain)&(len(domain)-1) != 0 {
+		panic("evaluations must be given over a domain of size a power of 2")
+	}
+
+	commitment, friTrees := friCommit(evals, domain, degreeBound, tr, opts)
+	decommitFRILayers(friQueries(tr, len(domain), opts), tr, friTrees, opts.FoldingFactor)
+
+	return commitment
+}
+
+// FRIVerify verifies a proof produced by FRIProve that the evaluations
+// committed to by the commitment are of a polynomial with less than
+// degreeBound coefficients.
+func FRIVerify(commitment []byte, domain []ff.FieldElement, degreeBound int, tr Transcript, opts ProofOptions) error {
+
+	if len(domain) == 0 || len(domain)&(len(domain)-1) != 0 {
+		return errors.New("domain size must be a power of 2")
+	}
+
+	verifier, err := readFRICommitments(commitment, domain, degreeBound, tr, opts)
+	if err != nil {
+		return err
+	}
+	indices, err := readFRIQueries(tr, len(domain), opts)
+	if err != nil {
+		return err
+	}
+	return verifier.VerifyQueries(tr, indices)
+}
+
+// friCommit commits to the evaluations and runs the commit phase, it
+// returns the commitment to the evaluations and the layer trees.
+func friCommit(evals []ff.FieldElement, domain []ff.FieldElement, degreeBound int, tr Transcript, opts ProofOptions) ([]byte, []*MerkleTree) {
+
+	tree := CommitFRILayer(evals, opts.FoldingFactor, opts.CapHeight, tr.Hasher())
+	commitment := tree.Commitment()
+	proverMessage(tr, "fri.evaluations", commitment)
+
+	_, _, friTrees := commitFRILayers(degreeBound, domain, evals, tree, tr, opts)
+
+	return commitment, friTrees
+}
+
+// readFRICommitments reads the commitment to the evaluations, which must be
+// the expected one, and the layer commitments.
+func readFRICommitments(commitment []byte, domain []ff.FieldElement, degreeBound int, tr Transcript, opts ProofOptions) (*FRIVerifier, error) {
+
+	sent, err := tr.Message("fri.evaluations", nil)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(sent, commitment) {
+		return nil, errors.New("proof doesn't commit to the given evaluations")
+	}
+
+	verifier := NewFRIVerifier(domain, degreeBound, tr.Hasher(), opts)
+	if err := verifier.ReadCommitments(tr, commitment); err != nil {
+		return nil, err
+	}
+	return verifier, nil
+}
+
+// friQueries grinds if required and draws the query indices
+func friQueries(tr Transcript, size int, opts ProofOptions) []int {
+
+	if opts.GrindingBits > 0 {
+		grind(tr, opts.GrindingBits)
+	}
+	return friQueryIndices(tr, size, opts)
+}
+
+// readFRIQueries checks the grinding nonce if required and draws the
+// query indices
+func readFRIQueries(tr Transcript, size int, opts ProofOptions) ([]int, error) {
+
+	if opts.GrindingBits > 0 {
+		if err := readGrinding(tr, opts.GrindingBits); err != nil {
+			return nil, err
+		}
+	}
+	return friQueryIndices(tr, size, opts), nil
+}
+
+// friQueryIndices draws the query indices in a domain of the given size
+func friQueryIndices(tr Transcript, size int, opts ProofOptions) []int {
+
+	indices := make([]int, opts.NumQueries)
+	for i, index := range tr.RandInts("query.index", len(indices), big.NewInt(0), big.NewInt(int64(size-1))) {
+		indices[i] = int(index.Int64())
+	}
+	return indices
+}
+
+// queriedLeaves returns the leaves holding the queried elements of a
+// function over a domain of the given size committed with CommitFRILayer
+func queriedLeaves(indices []int, size int, factor int) []int {
+
+	leaves := make([]int, len(indices))
+	for q, index := range indices {
+		leaves[q] = bitReverseIndex(index%size, log2(size)) / factor
+	}
+	return leaves
+}
+
+// openLeaves sends the leaves of the tree and their multi-proof
+func openLeaves(tr Transcript, tree *MerkleTree, leaves []int, leafLabel string, proofLabel string) {
+
+	for _, leaf := range leaves {
+		proverMessage(tr, leafLabel, tree.Leaf(leaf))
+	}
+	multiProof, err := tree.OpenMulti(leaves)
+	if err != nil {
+		panic(err)
+	}
+	proverMessage(tr, proofLabel, serializeMultiProof(multiProof))
+}
+
+// readLeaves reads leaves sent by openLeaves and checks their multi-proof
+// against the commitment of a tree with the given number of leaves and cap
+// height.
+func readLeaves(tr Transcript, commitment []byte, treeSize int, capHeight int, leaves []int, leafLabel string, proofLabel string) ([][]byte, error) {
+
+	cap, err := parseCap(tr.Hasher(), commitment, treeSize, capHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	opened := make([][]byte, len(leaves))
+	for q := range leaves {
+		leaf, err := tr.Message(leafLabel, nil)
+		if err != nil {
+			return nil, err
+		}
+		opened[q] = leaf
+	}
+	proof, err := tr.Message(proofLabel, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !VerifyMerkleCapMultiProof(tr.Hasher(), cap, treeSize, leaves, opened, splitHashes(tr.Hasher(), proof)) {
+		return nil, fmt.Errorf("bad multi-proof for %s", leafLabel)
+	}
+	return opened, nil
+}
@@ -0,0 +1,87 @@
+package zkstarks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLowDegreeTest(t *testing.T) {
+
+	coeffs := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+	prove := func(h Hasher, opts ProofOptions, coeffs ...int) ([]byte, *Proof) {
+		_, domain, evals, _ := testFRIInstance(64, coeffs...)
+		return proveTranscriptWithHasher(h, func(tr Transcript) []byte {
+			return FRIProve(evals, domain, 16, tr, opts)
+		})
+	}
+	verify := func(commitment []byte, proof *Proof, opts ProofOptions) error {
+		_, domain, _, _ := testFRIInstance(64, 0)
+		return verifyTranscript(proof, func(tr Transcript) error {
+			return FRIVerify(commitment, domain, 16, tr, opts)
+		})
+	}
+
+	t.Run("TestProveAndVerify", func(t *testing.T) {
+		for _, h := range []Hasher{NewSHA3Hasher(), NewBlake3Hasher()} {
+			for _, factor := range []int{2, 4} {
+				opts := DefaultProofOptions()
+				opts.FoldingFactor, opts.NumQueries = factor, 8
+				commitment, proof := prove(h, opts, coeffs...)
+				assert.NoError(t, verify(commitment, proof, opts), "%s factor %d", h.Name(), factor)
+			}
+		}
+	})
+	t.Run("TestGrinding", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.GrindingBits = 6
+		commitment, proof := prove(NewSHA3Hasher(), opts, coeffs...)
+		assert.NoError(t, verify(commitment, proof, opts))
+
+		for i, msg := range proof.Messages {
+			if msg.Label == "grinding.nonce" {
+				proof.Messages[i].Data = encodeNonce(NewSHA3Hasher(), 1<<40)
+			}
+		}
+		assert.Error(t, verify(commitment, proof, opts))
+	})
+	t.Run("TestRejectWrongCommitment", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		_, proof := prove(NewSHA3Hasher(), opts, coeffs...)
+		other, _ := prove(NewSHA3Hasher(), opts, 0)
+		assert.Error(t, verify(other, proof, opts))
+	})
+	t.Run("TestRejectHighDegree", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.NumQueries = 8
+		commitment, proof := prove(NewSHA3Hasher(), opts, append(coeffs, 17)...)
+		assert.Error(t, verify(commitment, proof, opts))
+	})
+	t.Run("TestTranscriptContinues", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		_, domain, evals, _ := testFRIInstance(64, coeffs...)
+		tr := NewProverTranscript(NewSHA3Hasher())
+		commitment := FRIProve(evals, domain, 16, tr, opts)
+		tr.Message("next", []byte{1})
+		after := tr.ChallengeBytes("next.challenge", 8)
+
+		vtr, err := NewVerifierTranscript(tr.Proof())
+		assert.NoError(t, err)
+		assert.NoError(t, FRIVerify(commitment, domain, 16, vtr, opts))
+		_, err = vtr.Message("next", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, after, vtr.ChallengeBytes("next.challenge", 8))
+		assert.NoError(t, vtr.Finalize())
+	})
+	t.Run("TestRejectOtherProximityTests", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.ProximityTest = STIRProximityTest
+		_, domain, evals, tree := testFRIInstance(64, 1, 2, 3, 4)
+		assert.Panics(t, func() { GenerateFRICommitmentWithOptions(4, domain, evals, tree, NewChannel(), opts) })
+		assert.Panics(t, func() { FRIDecommitWithOptions(NewChannel(), tree, nil, opts) })
+
+		opts.ProximityTest = FRIProximityTest
+		assert.NotPanics(t, func() { GenerateFRICommitmentWithOptions(4, domain, evals, tree, NewChannel(), opts) })
+	})
+}
@@ -0,0 +1,356 @@
+package zkstarks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/actuallyachraf/go-merkle"
+)
+
+// Commitments to evaluations are done using merkle trees, the go-merkle
+// package only exposes stateless functions that rebuild the whole tree
+// on each call to Root or Proof which makes decommitting on many queries
+// quadratic in the number of leaves.
+// MerkleTree is built once per commitment and keeps every layer of internal
+// nodes in memory, opening a leaf is then a walk from the leaf to the root.
+// The hashing scheme is the same as go-merkle i.e leaves are hashed as
+// H(0x00 || leaf) and internal nodes as H(0x01 || left || right) so roots
+// computed by both implementations are equal when using SHA3-256.
+
+var (
+	leafPrefix     = []byte{0x00}
+	interiorPrefix = []byte{0x01}
+)
+
+// Instead of a single root a tree can be committed to by a cap of 2^k nodes
+// taken k levels below the root, authentication paths then stop at the cap
+// level which removes k nodes from every path, when many queries are opened
+// the cap is smaller than the nodes it removes.
+
+// MerkleTree represents a merkle tree with cached internal nodes
+type MerkleTree struct {
+	leaves [][]byte
+	// layers[0] holds the leaf hashes and layers[len(layers)-1] the root
+	layers    [][][]byte
+	capHeight int
+	hasher    Hasher
+}
+
+// NewMerkleTree builds a merkle tree over the given leaves, the number
+// of leaves must be a power of two.
+func NewMerkleTree(leaves [][]byte) *MerkleTree {
+	return NewMerkleTreeWithCap(leaves, 0)
+}
+
+// NewMerkleTreeWithCap builds a merkle tree committed by a cap of height
+// capHeight, if the tree is shallower than the cap the cap is the leaf layer.
+func NewMerkleTreeWithCap(leaves [][]byte, capHeight int) *MerkleTree {
+	return NewMerkleTreeWithHasher(leaves, capHeight, NewSHA3Hasher())
+}
+
+// NewMerkleTreeWithHasher builds a merkle tree with a cap of height capHeight
+// using the given hash function.
+func NewMerkleTreeWithHasher(leaves [][]byte, capHeight int, h Hasher) *MerkleTree {
+
+	if len(leaves) == 0 || len(leaves)&(len(leaves)-1) != 0 {
+		panic("merkle tree size must be a power of two")
+	}
+
+	layer := make([][]byte, len(leaves))
+	for idx, leaf := range leaves {
+		layer[idx] = hashLeaf(h, leaf)
+	}
+	layers := [][][]byte{layer}
+
+	for len(layer) > 1 {
+		next := make([][]byte, len(layer)/2)
+		for idx := range next {
+			next[idx] = hashInterior(h, layer[2*idx], layer[2*idx+1])
+		}
+		layers = append(layers, next)
+		layer = next
+	}
+
+	if capHeight < 0 {
+		panic("merkle cap height must be non-negative")
+	}
+	if capHeight > len(layers)-1 {
+		capHeight = len(layers) - 1
+	}
+
+	return &MerkleTree{
+		leaves:    leaves,
+		layers:    layers,
+		capHeight: capHeight,
+		hasher:    h,
+	}
+}
+
+// Root returns the merkle root of the tree
+func (t *MerkleTree) Root() []byte {
+	return t.layers[len(t.layers)-1][0]
+}
+
+// CapHeight returns the height of the tree's cap
+func (t *MerkleTree) CapHeight() int {
+	return t.capHeight
+}
+
+// Hasher returns the hash function used by the tree
+func (t *MerkleTree) Hasher() Hasher {
+	return t.hasher
+}
+
+// Cap returns the 2^k nodes the tree is committed by
+func (t *MerkleTree) Cap() [][]byte {
+	return t.layers[len(t.layers)-1-t.capHeight]
+}
+
+// Commitment returns the serialized cap which is sent trough the channel
+// for a cap of height 0 it's the merkle root.
+func (t *MerkleTree) Commitment() []byte {
+	return serializeMultiProof(t.Cap())
+}
+
+// Size returns the number of leaves in the tree
+func (t *MerkleTree) Size() int {
+	return len(t.leaves)
+}
+
+// Leaf returns the leaf at the given index
+func (t *MerkleTree) Leaf(index int) []byte {
+	return t.leaves[index]
+}
+
+// Open returns the authentication path of the leaf at the given index
+// the path is ordered from the leaf to the cap.
+func (t *MerkleTree) Open(index int) ([]merkle.AuditHash, error) {
+
+	if index < 0 || index >= len(t.leaves) {
+		return nil, errors.New("merkle tree index out of bounds")
+	}
+
+	auditPath := make([]merkle.AuditHash, 0, len(t.layers)-1)
+
+	for _, layer := range t.layers[:len(t.layers)-1-t.capHeight] {
+		sibling := index ^ 1
+		auditPath = append(auditPath, merkle.AuditHash{
+			Val:           layer[sibling],
+			RightOperator: sibling > index,
+		})
+		index >>= 1
+	}
+
+	return auditPath, nil
+}
+
+// VerifyMerkleProof checks an authentication path for a leaf against a root.
+func VerifyMerkleProof(hasher Hasher, root []byte, leaf []byte, auditPath []merkle.AuditHash) bool {
+
+	h := hashLeaf(hasher, leaf)
+
+	for _, node := range auditPath {
+		if node.RightOperator {
+			h = hashInterior(hasher, h, node.Val)
+		} else {
+			h = hashInterior(hasher, node.Val, h)
+		}
+	}
+
+	return bytes.Equal(h, root)
+}
+
+// VerifyMerkleCapProof checks an authentication path for the leaf at index
+// against a merkle cap.
+func VerifyMerkleCapProof(hasher Hasher, cap [][]byte, index int, leaf []byte, auditPath []merkle.AuditHash) bool {
+
+	h := hashLeaf(hasher, leaf)
+
+	for _, node := range auditPath {
+		if node.RightOperator != (index%2 == 0) {
+			return false
+		}
+		if node.RightOperator {
+			h = hashInterior(hasher, h, node.Val)
+		} else {
+			h = hashInterior(hasher, node.Val, h)
+		}
+		index >>= 1
+	}
+
+	return index >= 0 && index < len(cap) && bytes.Equal(h, cap[index])
+}
+
+func hashLeaf(h Hasher, leaf []byte) []byte {
+	return h.Hash(leafPrefix, leaf)
+}
+
+func hashInterior(h Hasher, left, right []byte) []byte {
+	return h.Hash(interiorPrefix, left, right)
+}
+
+// When decommitting on many queries at once the authentication paths of
+// nearby leaves share most of their nodes, a multi-proof for a set of leaves
+// contains each sibling hash needed to recompute the root exactly once.
+// Nodes are ordered layer by layer starting from the leaves and by ascending
+// index inside each layer, nodes that can be computed from the opened leaves
+// are omitted.
+
+// OpenMulti returns the multi-proof for the leaves at the given indices.
+func (t *MerkleTree) OpenMulti(indices []int) ([][]byte, error) {
+
+	known := uniqueSorted(indices)
+	for _, index := range known {
+		if index < 0 || index >= len(t.leaves) {
+			return nil, errors.New("merkle tree index out of bounds")
+		}
+	}
+
+	proof := make([][]byte, 0)
+
+	for _, layer := range t.layers[:len(t.layers)-1-t.capHeight] {
+		for pos, index := range known {
+			sibling := index ^ 1
+			if sibling < index && pos > 0 && known[pos-1] == sibling {
+				continue
+			}
+			if sibling > index && pos+1 < len(known) && known[pos+1] == sibling {
+				continue
+			}
+			proof = append(proof, layer[sibling])
+		}
+		known = parentIndices(known)
+	}
+
+	return proof, nil
+}
+
+// VerifyMerkleMultiProof checks a multi-proof for the given leaves against
+// the root of a tree with size leaves, leaves[i] is the leaf at indices[i].
+func VerifyMerkleMultiProof(hasher Hasher, root []byte, size int, indices []int, leaves [][]byte, proof [][]byte) bool {
+	return VerifyMerkleCapMultiProof(hasher, [][]byte{root}, size, indices, leaves, proof)
+}
+
+// VerifyMerkleCapMultiProof checks a multi-proof for the given leaves against
+// the cap of a tree with size leaves.
+func VerifyMerkleCapMultiProof(hasher Hasher, cap [][]byte, size int, indices []int, leaves [][]byte, proof [][]byte) bool {
+
+	if len(indices) != len(leaves) || len(indices) == 0 || size == 0 || size&(size-1) != 0 {
+		return false
+	}
+	if len(cap) == 0 || len(cap)&(len(cap)-1) != 0 || len(cap) > size {
+		return false
+	}
+
+	nodes := make(map[int][]byte, len(indices))
+	for i, index := range indices {
+		if index < 0 || index >= size {
+			return false
+		}
+		h := hashLeaf(hasher, leaves[i])
+		if prev, ok := nodes[index]; ok && !bytes.Equal(prev, h) {
+			return false
+		}
+		nodes[index] = h
+	}
+	known := uniqueSorted(indices)
+
+	for ; size > len(cap); size /= 2 {
+		next := make(map[int][]byte, len(known))
+		for pos := 0; pos < len(known); pos++ {
+			index := known[pos]
+			var left, right []byte
+			if index%2 == 0 {
+				left = nodes[index]
+				if pos+1 < len(known) && known[pos+1] == index+1 {
+					right = nodes[index+1]
+					pos++
+				} else {
+					if len(proof) == 0 {
+						return false
+					}
+					right, proof = proof[0], proof[1:]
+				}
+			} else {
+				if len(proof) == 0 {
+					return false
+				}
+				left, proof = proof[0], proof[1:]
+				right = nodes[index]
+			}
+			next[index/2] = hashInterior(hasher, left, right)
+		}
+		nodes = next
+		known = parentIndices(known)
+	}
+
+	if len(proof) != 0 {
+		return false
+	}
+	for _, index := range known {
+		if !bytes.Equal(nodes[index], cap[index]) {
+			return false
+		}
+	}
+	return true
+}
+
+// uniqueSorted returns a sorted copy of indices without duplicates
+func uniqueSorted(indices []int) []int {
+
+	sorted := make([]int, len(indices))
+	copy(sorted, indices)
+	sort.Ints(sorted)
+
+	unique := make([]int, 0, len(sorted))
+	for i, index := range sorted {
+		if i == 0 || sorted[i-1] != index {
+			unique = append(unique, index)
+		}
+	}
+	return unique
+}
+
+// parentIndices returns the sorted unique parents of sorted node indices
+func parentIndices(indices []int) []int {
+
+	parents := make([]int, 0, len(indices))
+	for _, index := range indices {
+		if len(parents) == 0 || parents[len(parents)-1] != index/2 {
+			parents = append(parents, index/2)
+		}
+	}
+	return parents
+}
+
+// CapSize returns the number of nodes in the cap of height capHeight of a
+// tree with size leaves.
+func CapSize(size int, capHeight int) int {
+	if capHeight >= log2(size) {
+		return size
+	}
+	return 1 << uint(capHeight)
+}
+
+// parseCap splits a commitment into the cap of a tree with size leaves and
+// checks it holds as many nodes as a cap of height capHeight.
+func parseCap(hasher Hasher, commitment []byte, size int, capHeight int) ([][]byte, error) {
+
+	cap := splitHashes(hasher, commitment)
+	if cap == nil || len(cap) != CapSize(size, capHeight) {
+		return nil, fmt.Errorf("commitment must be a cap of %d nodes", CapSize(size, capHeight))
+	}
+	return cap, nil
+}
+
+// serializeMultiProof serializes a merkle multi-proof or cap
+func serializeMultiProof(proof [][]byte) []byte {
+	var b = make([]byte, 0)
+
+	for _, node := range proof {
+		b = append(b, node...)
+	}
+	return b
+}
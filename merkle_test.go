@@ -0,0 +1,155 @@
+package zkstarks
+
+import (
+	"testing"
+
+	"github.com/actuallyachraf/go-merkle"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerkleTree(t *testing.T) {
+
+	leaves := make([][]byte, 16)
+	for i := range leaves {
+		leaves[i] = []byte{byte(i), byte(i * 7)}
+	}
+	tree := NewMerkleTree(leaves)
+
+	t.Run("TestRootMatchesGoMerkle", func(t *testing.T) {
+		assert.Equal(t, merkle.Root(leaves), tree.Root())
+		assert.Equal(t, merkle.Root(leaves[:1]), NewMerkleTree(leaves[:1]).Root())
+	})
+	t.Run("TestOpenMatchesGoMerkle", func(t *testing.T) {
+		for i := range leaves {
+			expected, err := merkle.Proof(leaves, i)
+			assert.NoError(t, err)
+			actual, err := tree.Open(i)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		}
+	})
+	t.Run("TestVerifyMerkleProof", func(t *testing.T) {
+		for i := range leaves {
+			auditPath, err := tree.Open(i)
+			assert.NoError(t, err)
+			assert.True(t, VerifyMerkleProof(tree.Hasher(), tree.Root(), tree.Leaf(i), auditPath))
+			assert.False(t, VerifyMerkleProof(tree.Hasher(), tree.Root(), []byte("bad leaf"), auditPath))
+		}
+		_, err := tree.Open(len(leaves))
+		assert.Error(t, err)
+	})
+	t.Run("TestNonPowerOfTwoPanics", func(t *testing.T) {
+		assert.Panics(t, func() { NewMerkleTree(leaves[:3]) })
+	})
+}
+
+func TestMerkleMultiProof(t *testing.T) {
+
+	leaves := make([][]byte, 32)
+	for i := range leaves {
+		leaves[i] = []byte{byte(i), byte(i * 3)}
+	}
+	tree := NewMerkleTree(leaves)
+
+	t.Run("TestMultiProofVerifies", func(t *testing.T) {
+		indices := []int{3, 2, 17, 30, 3, 31}
+		opened := make([][]byte, len(indices))
+		for i, index := range indices {
+			opened[i] = tree.Leaf(index)
+		}
+		proof, err := tree.OpenMulti(indices)
+		assert.NoError(t, err)
+		assert.True(t, VerifyMerkleMultiProof(tree.Hasher(), tree.Root(), tree.Size(), indices, opened, proof))
+
+		opened[2] = []byte("bad leaf")
+		assert.False(t, VerifyMerkleMultiProof(tree.Hasher(), tree.Root(), tree.Size(), indices, opened, proof))
+	})
+	t.Run("TestMultiProofDeduplicates", func(t *testing.T) {
+		// Opening 5 leaves separately sends 25 nodes, siblings 2,3 and 30,31
+		// are computed from the opened leaves and the upper nodes of
+		// the three paths are shared, only 8 nodes are needed.
+		proof, err := tree.OpenMulti([]int{2, 3, 17, 30, 31})
+		assert.NoError(t, err)
+
+		separate := 0
+		for _, index := range []int{2, 3, 17, 30, 31} {
+			path, err := tree.Open(index)
+			assert.NoError(t, err)
+			separate += len(path)
+		}
+		assert.Equal(t, 8, len(proof))
+		assert.True(t, len(proof) < separate)
+	})
+	t.Run("TestSingleLeafMatchesOpen", func(t *testing.T) {
+		proof, err := tree.OpenMulti([]int{9})
+		assert.NoError(t, err)
+		path, err := tree.Open(9)
+		assert.NoError(t, err)
+		for i := range path {
+			assert.Equal(t, path[i].Val, proof[i])
+		}
+	})
+	t.Run("TestMultiProofRejectsTruncatedProof", func(t *testing.T) {
+		indices := []int{1, 12}
+		proof, err := tree.OpenMulti(indices)
+		assert.NoError(t, err)
+		opened := [][]byte{tree.Leaf(1), tree.Leaf(12)}
+		assert.False(t, VerifyMerkleMultiProof(tree.Hasher(), tree.Root(), tree.Size(), indices, opened, proof[1:]))
+		assert.False(t, VerifyMerkleMultiProof(tree.Hasher(), tree.Root(), tree.Size(), indices, opened, append(proof, proof[0])))
+	})
+}
+
+func TestMerkleCap(t *testing.T) {
+
+	leaves := make([][]byte, 32)
+	for i := range leaves {
+		leaves[i] = []byte{byte(i), byte(i * 5)}
+	}
+	tree := NewMerkleTreeWithCap(leaves, 2)
+
+	t.Run("TestCapLayer", func(t *testing.T) {
+		assert.Len(t, tree.Cap(), 4)
+		assert.Len(t, tree.Commitment(), 4*32)
+		assert.Equal(t, NewMerkleTree(leaves).Root(), tree.Root())
+		assert.Equal(t, tree.Root(), NewMerkleTree(leaves).Commitment())
+		// caps higher than the tree are clamped to the leaf layer
+		assert.Len(t, NewMerkleTreeWithCap(leaves[:4], 5).Cap(), 4)
+		assert.Panics(t, func() { NewMerkleTreeWithCap(leaves, -1) })
+	})
+	t.Run("TestParseCap", func(t *testing.T) {
+		assert.Equal(t, 4, CapSize(32, 2))
+		assert.Equal(t, 1, CapSize(32, 0))
+		assert.Equal(t, 4, CapSize(4, 5))
+
+		cap, err := parseCap(tree.Hasher(), tree.Commitment(), tree.Size(), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, tree.Cap(), cap)
+		_, err = parseCap(tree.Hasher(), tree.Commitment(), tree.Size(), 1)
+		assert.Error(t, err)
+		_, err = parseCap(tree.Hasher(), tree.Commitment()[:4*32-1], tree.Size(), 2)
+		assert.Error(t, err)
+	})
+	t.Run("TestCappedPathVerifies", func(t *testing.T) {
+		for i := range leaves {
+			auditPath, err := tree.Open(i)
+			assert.NoError(t, err)
+			assert.Len(t, auditPath, 3)
+			assert.True(t, VerifyMerkleCapProof(tree.Hasher(), tree.Cap(), i, tree.Leaf(i), auditPath))
+			assert.False(t, VerifyMerkleCapProof(tree.Hasher(), tree.Cap(), i^8, tree.Leaf(i), auditPath))
+		}
+	})
+	t.Run("TestCappedMultiProofVerifies", func(t *testing.T) {
+		indices := []int{0, 1, 9, 12}
+		opened := make([][]byte, len(indices))
+		for i, index := range indices {
+			opened[i] = tree.Leaf(index)
+		}
+		proof, err := tree.OpenMulti(indices)
+		assert.NoError(t, err)
+		uncapped, err := NewMerkleTree(leaves).OpenMulti(indices)
+		assert.NoError(t, err)
+		assert.True(t, len(proof) < len(uncapped))
+		assert.True(t, VerifyMerkleCapMultiProof(tree.Hasher(), tree.Cap(), tree.Size(), indices, opened, proof))
+		assert.False(t, VerifyMerkleMultiProof(tree.Hasher(), tree.Root(), tree.Size(), indices, opened, proof))
+	})
+}
@@ -0,0 +1,160 @@
+package zkstarks
+
+import (
+	"encoding/binary"
+	"math/big"
+)
+
+// MerlinTranscript implements Merlin transcripts (https://merlin.cool)
+// a transcript is a STROBE-128 instance where :
+// - AppendMessage(label,m) runs meta-AD(label) meta-AD(LE32(len(m))) AD(m)
+// - ChallengeBytes(label,n) runs meta-AD(label) meta-AD(LE32(n)) PRF(n)
+// Proofs produced with a MerlinTranscript compose with other protocols
+// using Merlin, the transcript can be shared by both.
+// MerlinTranscript is a prover Transcript, Merlin has no hash function of
+// its own so the transcript carries the one Merkle trees and grinding use,
+// a verifier replays the proof with NewMerlinVerifierTranscript.
+type MerlinTranscript struct {
+	strobe *strobe128
+	hasher Hasher
+	proof  *Proof
+}
+
+// NewMerlinTranscript creates a transcript for the protocol with the given label
+func NewMerlinTranscript(label string) *MerlinTranscript {
+	return NewMerlinTranscriptWithHasher(label, NewSHA3Hasher())
+}
+
+// NewMerlinTranscriptWithHasher creates a transcript for the protocol with
+// the given label using the given hash function.
+func NewMerlinTranscriptWithHasher(label string, h Hasher) *MerlinTranscript {
+
+	t := &MerlinTranscript{
+		strobe: newStrobe128([]byte("Merlin v1.0")),
+		hasher: h,
+		proof:  &Proof{Hash: h.Name()},
+	}
+	t.AppendMessage("dom-sep", []byte(label))
+	return t
+}
+
+// AppendMessage absorbs a labelled prover message
+func (t *MerlinTranscript) AppendMessage(label string, data []byte) {
+
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(data)))
+
+	t.strobe.metaAD([]byte(label), false)
+	t.strobe.metaAD(length[:], true)
+	t.strobe.ad(data, false)
+}
+
+// ChallengeBytes derives n labelled challenge bytes
+func (t *MerlinTranscript) ChallengeBytes(label string, n int) []byte {
+
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(n))
+
+	t.strobe.metaAD([]byte(label), false)
+	t.strobe.metaAD(length[:], true)
+	return t.strobe.prf(n, false)
+}
+
+// Send is an alias of AppendMessage
+func (t *MerlinTranscript) Send(label string, data []byte) {
+	t.AppendMessage(label, data)
+}
+
+// Message absorbs and records a prover message
+func (t *MerlinTranscript) Message(label string, data []byte) ([]byte, error) {
+	t.proof.Messages = append(t.proof.Messages, ProofMessage{Label: label, Data: data})
+	t.AppendMessage(label, data)
+	return data, nil
+}
+
+// Proof returns the proof recorded so far
+func (t *MerlinTranscript) Proof() *Proof {
+	return t.proof
+}
+
+// State returns the STROBE state and position which commit to every
+// operation run so far, it doesn't run an operation itself.
+func (t *MerlinTranscript) State() []byte {
+	state := make([]byte, 0, len(t.strobe.state)+1)
+	state = append(state, t.strobe.state[:]...)
+	return append(state, byte(t.strobe.pos))
+}
+
+// Hasher returns the hash function of the transcript
+func (t *MerlinTranscript) Hasher() Hasher {
+	return t.hasher
+}
+
+// RandInt derives a uniform integer in [min,max]
+func (t *MerlinTranscript) RandInt(label string, min, max *big.Int) *big.Int {
+	return t.RandInts(label, 1, min, max)[0]
+}
+
+// RandInts derives n uniform integers in [min,max] by rejection sampling
+// each candidate is a separate challenge with the same label.
+func (t *MerlinTranscript) RandInts(label string, n int, min, max *big.Int) []*big.Int {
+
+	read := func(size int) []byte {
+		return t.ChallengeBytes(label, size)
+	}
+	nums := make([]*big.Int, n)
+	for i := range nums {
+		nums[i] = sampleUniformInt(read, min, max)
+	}
+	return nums
+}
+
+// RandFE derives a uniform field element given the field's modulus
+func (t *MerlinTranscript) RandFE(label string, m *big.Int) *big.Int {
+	return t.RandFEs(label, 1, m)[0]
+}
+
+// RandFEs derives n uniform field elements given the field's modulus
+func (t *MerlinTranscript) RandFEs(label string, n int, m *big.Int) []*big.Int {
+	return t.RandInts(label, n, big.NewInt(0), new(big.Int).Sub(m, big.NewInt(1)))
+}
+
+// MerlinVerifierTranscript reads the prover's messages from a proof recorded
+// by a MerlinTranscript and replays them on a transcript of the same protocol.
+type MerlinVerifierTranscript struct {
+	*MerlinTranscript
+	next int
+}
+
+// NewMerlinVerifierTranscript creates a verifier transcript for the protocol
+// with the given label over the proof using the hash function recorded in
+// its header.
+func NewMerlinVerifierTranscript(label string, proof *Proof) (*MerlinVerifierTranscript, error) {
+
+	h, err := HasherFromName(proof.Hash)
+	if err != nil {
+		return nil, err
+	}
+	t := NewMerlinTranscriptWithHasher(label, h)
+	t.proof = proof
+
+	return &MerlinVerifierTranscript{MerlinTranscript: t}, nil
+}
+
+// Message reads and absorbs the next message of the proof which must
+// carry the given label.
+func (tr *MerlinVerifierTranscript) Message(label string, _ []byte) ([]byte, error) {
+
+	data, err := readProofMessage(tr.proof, &tr.next, label)
+	if err != nil {
+		return nil, err
+	}
+	tr.AppendMessage(label, data)
+
+	return data, nil
+}
+
+// Finalize checks that every message of the proof was read
+func (tr *MerlinVerifierTranscript) Finalize() error {
+	return finalizeProof(tr.proof, tr.next)
+}
@@ -0,0 +1,115 @@
+package zkstarks
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/actuallyachraf/algebra/nt"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/sha3"
+)
+
+func TestMerlinTranscript(t *testing.T) {
+
+	t.Run("TestKeccakF1600", func(t *testing.T) {
+		// Keccak-256 of a single block message built on keccakF1600
+		// should match the legacy keccak implementation of x/crypto.
+		msg := []byte("zkstarks")
+		var block [136]byte
+		copy(block[:], msg)
+		block[len(msg)] ^= 0x01
+		block[135] ^= 0x80
+
+		var lanes [25]uint64
+		for i := 0; i < 17; i++ {
+			for j := 0; j < 8; j++ {
+				lanes[i] |= uint64(block[8*i+j]) << (8 * uint(j))
+			}
+		}
+		keccakF1600(&lanes)
+		digest := make([]byte, 32)
+		for i := range digest {
+			digest[i] = byte(lanes[i/8] >> (8 * uint(i%8)))
+		}
+
+		h := sha3.NewLegacyKeccak256()
+		h.Write(msg)
+		assert.Equal(t, h.Sum(nil), digest)
+	})
+	t.Run("TestSimpleTranscript", func(t *testing.T) {
+		mt := NewMerlinTranscript("test protocol")
+		mt.AppendMessage("some label", []byte("some data"))
+
+		challenge := mt.ChallengeBytes("challenge", 32)
+		assert.Equal(t, "d5a21972d0d5fe320c0d263fac7fffb8145aa640af6e9bca177c03c7efcf0615", hex.EncodeToString(challenge))
+	})
+	t.Run("TestComplexTranscript", func(t *testing.T) {
+		mt := NewMerlinTranscript("test protocol")
+		mt.AppendMessage("step1", []byte("some data"))
+
+		data := make([]byte, 1024)
+		for i := range data {
+			data[i] = 99
+		}
+
+		var challenge []byte
+		for i := 0; i < 32; i++ {
+			challenge = mt.ChallengeBytes("challenge", 32)
+			mt.AppendMessage("bigdata", data)
+			mt.AppendMessage("challengedata", challenge)
+		}
+		assert.Equal(t, "a8c933f54fae76e3f9bea93648c1308e7dfa2152dd51674ff3ca438351cf003c", hex.EncodeToString(challenge))
+	})
+	t.Run("TestFiatShamirInterface", func(t *testing.T) {
+		for _, fs := range []FiatShamir{NewChannel(), NewMerlinTranscript("zkstarks")} {
+			fs.Send("commitment", []byte("Yes"))
+			r := fs.RandInts("query", 16, nt.FromInt64(3), nt.FromInt64(9))
+			for _, x := range r {
+				assert.True(t, x.Cmp(nt.FromInt64(3)) >= 0 && x.Cmp(nt.FromInt64(9)) <= 0)
+			}
+			fe := fs.RandFE("beta", PrimeField.Modulus())
+			assert.True(t, fe.Cmp(PrimeField.Modulus()) < 0)
+			assert.Len(t, fs.ChallengeBytes("bytes", 48), 48)
+		}
+
+		a := NewMerlinTranscript("zkstarks")
+		b := NewMerlinTranscript("zkstarks")
+		a.Send("commitment", []byte("Yes"))
+		b.Send("commitment", []byte("No"))
+		assert.NotEqual(t, a.RandFE("beta", PrimeField.Modulus()), b.RandFE("beta", PrimeField.Modulus()))
+	})
+	t.Run("TestTranscript", func(t *testing.T) {
+		_, domain, evals, _ := testFRIInstance(64, 1, 2, 3, 4, 5, 6, 7, 8)
+		opts := DefaultProofOptions()
+		opts.GrindingBits, opts.NumQueries = 4, 8
+
+		prover := NewMerlinTranscriptWithHasher("zkstarks", NewBlake2sHasher())
+		commitment := FRIProve(evals, domain, 8, prover, opts)
+		proof := prover.Proof()
+		assert.Equal(t, "blake2s-256", proof.Hash)
+
+		verifier, err := NewMerlinVerifierTranscript("zkstarks", proof)
+		assert.NoError(t, err)
+		assert.NoError(t, FRIVerify(commitment, domain, 8, verifier, opts))
+		assert.NoError(t, verifier.Finalize())
+		// both sides end in the same state
+		assert.Equal(t, prover.State(), verifier.State())
+		assert.Equal(t, prover.ChallengeBytes("next", 32), verifier.ChallengeBytes("next", 32))
+
+		// the protocol label is part of the transcript
+		verifier, err = NewMerlinVerifierTranscript("other", proof)
+		assert.NoError(t, err)
+		assert.Error(t, FRIVerify(commitment, domain, 8, verifier, opts))
+
+		_, err = NewMerlinVerifierTranscript("zkstarks", &Proof{Hash: "unknown"})
+		assert.Error(t, err)
+
+		assertRejectsTamperedMessages(t, proof, func(proof *Proof) error {
+			verifier, err := NewMerlinVerifierTranscript("zkstarks", proof)
+			if err != nil {
+				return err
+			}
+			return FRIVerify(commitment, domain, 8, verifier, opts)
+		}, "fri.evaluations", "fri.layer", "grinding.nonce", "fri.coset")
+	})
+}
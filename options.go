@@ -0,0 +1,32 @@
+package zkstarks
+
+// ProofOptions represents the parameters of the query phase
+type ProofOptions struct {
+	// NumQueries is the number of query indices drawn by the verifier
+	NumQueries int
+	// GrindingBits is the number of leading zero bits required from the
+	// proof of work, grinding is skipped when it is 0
+	GrindingBits int
+	// FoldingFactor is the number of points folded together by each FRI
+	// round, a power of 2
+	FoldingFactor int
+	// RemainderDegreeBound is the number of coefficients of the remainder
+	// polynomial sent once folding stops, 1 folds until it is constant
+	RemainderDegreeBound int
+	// CapHeight is the height of the merkle caps committing to each tree,
+	// trees are committed to by their root when it is 0
+	CapHeight int
+	// ProximityTest is the name of the low degree test, FRI is used when
+	// it is empty
+	ProximityTest string
+}
+
+// DefaultProofOptions returns the options used by FRIDecommit
+func DefaultProofOptions() ProofOptions {
+	return ProofOptions{
+		NumQueries:           3,
+		GrindingBits:         0,
+		FoldingFactor:        2,
+		RemainderDegreeBound: 1,
+	}
+}
@@ -0,0 +1,178 @@
+package zkstarks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"github.com/actuallyachraf/algebra/poly"
+)
+
+// A polynomial p with less than d coefficients is committed to by the merkle
+// tree of its evaluations over a coset L (in the layout of CommitFRILayer).
+// To open p at a point z outside of L the prover sends v = p(z) and proves
+// that the quotient q(X) = (p(X) - v) / (X - z) has less than d - 1
+// coefficients with FRI, which is only possible if p(z) = v.
+// FRI runs on the committed evaluations of q, at each query the prover also
+// opens the block of p and the verifier checks that q = (p - v) / (X - z)
+// on the points of the block.
+// Commitments, points and values are absorbed by the transcript before any
+// challenge is drawn.
+
+// PCS represents the FRI based polynomial commitment scheme over a domain
+type PCS struct {
+	domain      []ff.FieldElement
+	degreeBound int
+	hasher      Hasher
+	opts        ProofOptions
+}
+
+// CommittedPolynomial represents a committed polynomial and the prover data
+// needed to open it.
+type CommittedPolynomial struct {
+	Commitment []byte
+	p          poly.Polynomial
+	evals      []ff.FieldElement
+	tree       *MerkleTree
+}
+
+// NewPCS creates a commitment scheme to polynomials with less than
+// degreeBound coefficients evaluated over the domain (a coset of size a
+// power of 2 in its natural order), openings must use transcripts with the
+// same hash function.
+// Quotients have less than degreeBound - 1 coefficients and are folded by
+// FRI until the remainder so degreeBound - 1 must exceed the options'
+// RemainderDegreeBound.
+func NewPCS(domain []ff.FieldElement, degreeBound int, hasher Hasher, opts ProofOptions) *PCS {
+
+	if degreeBound-1 <= opts.RemainderDegreeBound {
+		panic(fmt.Sprintf("PCS degree bound must exceed the remainder degree bound %d by more than 1", opts.RemainderDegreeBound))
+	}
+	return &PCS{
+		domain:      domain,
+		degreeBound: degreeBound,
+		hasher:      hasher,
+		opts:        opts,
+	}
+}
+
+// Commit commits to the polynomial
+func (pcs *PCS) Commit(p poly.Polynomial) *CommittedPolynomial {
+
+	if len(p) > pcs.degreeBound {
+		panic("polynomial exceeds the degree bound")
+	}
+	evals := make([]ff.FieldElement, len(pcs.domain))
+	for i, x := range pcs.domain {
+		evals[i] = PrimeField.NewFieldElement(p.Eval(x.Big(), PrimeField.Modulus()))
+	}
+	tree := CommitFRILayer(evals, pcs.opts.FoldingFactor, pcs.opts.CapHeight, pcs.hasher)
+
+	return &CommittedPolynomial{
+		Commitment: tree.Commitment(),
+		p:          p,
+		evals:      evals,
+		tree:       tree,
+	}
+}
+
+// Open opens the committed polynomial at z and returns its value, the proof
+// is recorded by the transcript.
+func (pcs *PCS) Open(c *CommittedPolynomial, z ff.FieldElement, tr Transcript) ff.FieldElement {
+
+	for _, x := range pcs.domain {
+		if x.Equal(z) {
+			panic("opening point lies in the domain")
+		}
+	}
+
+	value := PrimeField.NewFieldElement(c.p.Eval(z.Big(), PrimeField.Modulus()))
+	proverMessage(tr, "pcs.commitment", c.Commitment)
+	proverMessage(tr, "pcs.point", encodeElements(tr.Hasher(), []ff.FieldElement{z}))
+	proverMessage(tr, "pcs.value", encodeElements(tr.Hasher(), []ff.FieldElement{value}))
+
+	quotient := make([]ff.FieldElement, len(pcs.domain))
+	for i, x := range pcs.domain {
+		quotient[i] = PrimeField.Div(PrimeField.Sub(c.evals[i], value), PrimeField.Sub(x, z))
+	}
+
+	_, friTrees := friCommit(quotient, pcs.domain, pcs.degreeBound-1, tr, pcs.opts)
+	indices := friQueries(tr, len(pcs.domain), pcs.opts)
+	decommitFRILayers(indices, tr, friTrees, pcs.opts.FoldingFactor)
+
+	openLeaves(tr, c.tree, queriedLeaves(indices, len(pcs.domain), pcs.opts.FoldingFactor), "pcs.opening", "pcs.multiproof")
+
+	return value
+}
+
+// VerifyOpening verifies that the polynomial committed to by the commitment
+// takes the value at z.
+func (pcs *PCS) VerifyOpening(commitment []byte, z ff.FieldElement, value ff.FieldElement, tr Transcript) error {
+
+	size := len(pcs.domain)
+	factor := pcs.opts.FoldingFactor
+	if size == 0 || size&(size-1) != 0 {
+		return errors.New("domain size must be a power of 2")
+	}
+	for _, x := range pcs.domain {
+		if x.Equal(z) {
+			return errors.New("opening point lies in the domain")
+		}
+	}
+
+	for _, expected := range []struct {
+		label string
+		data  []byte
+	}{
+		{"pcs.commitment", commitment},
+		{"pcs.point", encodeElements(tr.Hasher(), []ff.FieldElement{z})},
+		{"pcs.value", encodeElements(tr.Hasher(), []ff.FieldElement{value})},
+	} {
+		sent, err := tr.Message(expected.label, nil)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(sent, expected.data) {
+			return fmt.Errorf("proof doesn't match the opening's %s", expected.label)
+		}
+	}
+
+	quotientCommitment, err := tr.Message("fri.evaluations", nil)
+	if err != nil {
+		return err
+	}
+	verifier := NewFRIVerifier(pcs.domain, pcs.degreeBound-1, tr.Hasher(), pcs.opts)
+	if err := verifier.ReadCommitments(tr, quotientCommitment); err != nil {
+		return err
+	}
+	indices, err := readFRIQueries(tr, size, pcs.opts)
+	if err != nil {
+		return err
+	}
+	if err := verifier.VerifyQueries(tr, indices); err != nil {
+		return err
+	}
+
+	leafIndices := queriedLeaves(indices, size, factor)
+	leaves, err := readLeaves(tr, commitment, size/factor, pcs.opts.CapHeight, leafIndices, "pcs.opening", "pcs.multiproof")
+	if err != nil {
+		return err
+	}
+	cosets := verifier.FirstLayerCosets()
+	for q, leaf := range leaves {
+		evals, err := decodeElements(tr.Hasher(), leaf)
+		if err != nil || len(evals) != factor {
+			return fmt.Errorf("bad opening at query %d", q)
+		}
+		for t, eval := range evals {
+			x := pcs.domain[bitReverseIndex(leafIndices[q]*factor+t, log2(size))]
+			expected := PrimeField.Div(PrimeField.Sub(eval, value), PrimeField.Sub(x, z))
+			if !expected.Equal(cosets[q][t]) {
+				return fmt.Errorf("quotient doesn't match the opening at query %d", q)
+			}
+		}
+	}
+
+	return nil
+}
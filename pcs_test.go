@@ -0,0 +1,93 @@
+package zkstarks
+
+import (
+	"testing"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"github.com/actuallyachraf/algebra/poly"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPCS(t *testing.T) {
+
+	_, domain, _, _ := testFRIInstance(64, 0)
+	p := poly.NewPolynomialInts(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16)
+	z := PrimeField.NewFieldElementFromInt64(1234567)
+
+	newPCS := func(factor int) *PCS {
+		opts := DefaultProofOptions()
+		opts.FoldingFactor, opts.NumQueries = factor, 8
+		return NewPCS(domain, 16, NewSHA3Hasher(), opts)
+	}
+	open := func(pcs *PCS, c *CommittedPolynomial, z ff.FieldElement) (ff.FieldElement, *Proof) {
+		var value ff.FieldElement
+		_, proof := proveTranscript(func(tr Transcript) []byte {
+			value = pcs.Open(c, z, tr)
+			return nil
+		})
+		return value, proof
+	}
+	verify := func(pcs *PCS, commitment []byte, z, value ff.FieldElement, proof *Proof) error {
+		return verifyTranscript(proof, func(tr Transcript) error {
+			return pcs.VerifyOpening(commitment, z, value, tr)
+		})
+	}
+
+	t.Run("TestOpenAndVerify", func(t *testing.T) {
+		for _, factor := range []int{2, 4} {
+			pcs := newPCS(factor)
+			c := pcs.Commit(p)
+			value, proof := open(pcs, c, z)
+
+			assert.True(t, value.Big().Cmp(p.Eval(z.Big(), PrimeField.Modulus())) == 0)
+			assert.NoError(t, verify(pcs, c.Commitment, z, value, proof), "factor %d", factor)
+		}
+	})
+	t.Run("TestRejectWrongValue", func(t *testing.T) {
+		pcs := newPCS(2)
+		c := pcs.Commit(p)
+		value, proof := open(pcs, c, z)
+
+		wrong := PrimeField.Add(value, PrimeField.One())
+		assert.Error(t, verify(pcs, c.Commitment, z, wrong, proof))
+		assert.Error(t, verify(pcs, c.Commitment, PrimeField.One(), value, proof))
+		assert.Error(t, verify(pcs, pcs.Commit(poly.NewPolynomialInts(1)).Commitment, z, value, proof))
+	})
+	t.Run("TestRejectForgedOpening", func(t *testing.T) {
+		// a prover lying on the value has to commit to a quotient which
+		// isn't of low degree
+		pcs := newPCS(2)
+		c := pcs.Commit(p)
+		c.p = poly.NewPolynomialInts(0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15)
+		value, proof := open(pcs, c, z)
+		assert.Error(t, verify(pcs, c.Commitment, z, value, proof))
+	})
+	t.Run("TestRejectTamperedProof", func(t *testing.T) {
+		pcs := newPCS(2)
+		c := pcs.Commit(p)
+		value, proof := open(pcs, c, z)
+		assertRejectsTamperedMessages(t, proof, func(proof *Proof) error {
+			return verify(pcs, c.Commitment, z, value, proof)
+		}, "pcs.commitment", "pcs.point", "pcs.value", "fri.commitment", "fri.coset")
+	})
+	t.Run("TestRejectPointInDomain", func(t *testing.T) {
+		pcs := newPCS(2)
+		c := pcs.Commit(p)
+		assert.Panics(t, func() { pcs.Open(c, domain[3], NewProverTranscript(NewSHA3Hasher())) })
+
+		value, proof := open(pcs, c, z)
+		assert.Error(t, verify(pcs, c.Commitment, domain[3], value, proof))
+	})
+	t.Run("TestRejectSmallDegreeBound", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		assert.Panics(t, func() { NewPCS(domain, 2, NewSHA3Hasher(), opts) })
+		assert.NotPanics(t, func() { NewPCS(domain, 3, NewSHA3Hasher(), opts) })
+
+		opts.RemainderDegreeBound = 4
+		assert.Panics(t, func() { NewPCS(domain, 5, NewSHA3Hasher(), opts) })
+		pcs := NewPCS(domain, 6, NewSHA3Hasher(), opts)
+		c := pcs.Commit(p[:6])
+		value, proof := open(pcs, c, z)
+		assert.NoError(t, verify(pcs, c.Commitment, z, value, proof))
+	})
+}
@@ -0,0 +1,170 @@
+package zkstarks
+
+import (
+	"fmt"
+
+	"github.com/actuallyachraf/algebra/ff"
+)
+
+// The permutation argument proves that the rows of a group of main columns
+// A are a permutation of the rows of a group of columns B.
+// Once the main segment is committed the challenges alpha and gamma are
+// drawn and each row is compressed to a_i = sum_j alpha^j A_j[i] (resp b_i),
+// the rows of both groups are the same multiset only if
+// prod_i (gamma - a_i) = prod_i (gamma - b_i)
+// except for few gammas.
+// The auxiliary segment holds the running product z with z_0 = 1 and
+// z_(i+1) = z_i (gamma - a_i) / (gamma - b_i) constrained by :
+// - the boundary constraint z_0 = 1
+// - the wrapping transition z' (gamma - b) = z (gamma - a) which between the
+// last row and the first one checks that the whole product is 1.
+// Since the running product is committed after the main segment a prover
+// can't pick the columns depending on the challenges.
+// The extended AIR is proved with ProveRAP and verified with VerifyRAP which
+// check the running products at the out of domain frame like any other
+// constraint.
+
+// PermutationArgument represents two groups of main columns whose rows are
+// permutations of each other.
+type PermutationArgument struct {
+	A []int
+	B []int
+}
+
+// permutationAIR extends an AIR with the running product column of each
+// permutation argument.
+type permutationAIR struct {
+	AIR
+	width     int
+	arguments []PermutationArgument
+}
+
+// WithPermutations returns the AIR extended with the constraints of the
+// permutation arguments over its main segment of the given width (at least
+// the AIR's own), the AIR must not draw challenges itself.
+func WithPermutations(air AIR, width int, arguments ...PermutationArgument) WrappingAIR {
+
+	if air.NumChallenges() != 0 || numWrappingTransitions(air) != 0 {
+		panic("permutation arguments extend AIRs without auxiliary segment")
+	}
+	if width < air.MainWidth() {
+		panic("permutation arguments can't narrow the AIR's main segment")
+	}
+	if len(arguments) == 0 {
+		panic("no permutation arguments")
+	}
+	for i, argument := range arguments {
+		if len(argument.A) == 0 || len(argument.A) != len(argument.B) {
+			panic(fmt.Sprintf("permutation argument %d needs groups of the same number of columns", i))
+		}
+		for _, column := range append(append([]int(nil), argument.A...), argument.B...) {
+			if column < 0 || column >= width {
+				panic(fmt.Sprintf("permutation argument %d uses missing column %d", i, column))
+			}
+		}
+	}
+	return &permutationAIR{
+		AIR:       air,
+		width:     width,
+		arguments: arguments,
+	}
+}
+
+// NumChallenges returns the number of challenges, alpha then gamma
+func (p *permutationAIR) NumChallenges() int {
+	return 2
+}
+
+// MainWidth returns the width of the main segment the arguments span
+func (p *permutationAIR) MainWidth() int {
+	return p.width
+}
+
+// AuxiliaryWidth returns the number of running products
+func (p *permutationAIR) AuxiliaryWidth() int {
+	return len(p.arguments)
+}
+
+// NumWrappingTransitions returns the number of running products
+func (p *permutationAIR) NumWrappingTransitions() int {
+	return len(p.arguments)
+}
+
+// AuxiliaryTrace builds the running product column of each argument
+func (p *permutationAIR) AuxiliaryTrace(main *Trace, challenges []ff.FieldElement) *Trace {
+
+	if main.Width() != p.width {
+		panic("main trace doesn't match the permutation's width")
+	}
+	n := main.Length()
+	alpha, gamma := challenges[0], challenges[1]
+	row := make([]ff.FieldElement, main.Width())
+
+	columns := make([][]ff.FieldElement, len(p.arguments))
+	for k, argument := range p.arguments {
+		z := make([]ff.FieldElement, n)
+		z[0] = PrimeField.One()
+		for i := 0; i < n-1; i++ {
+			for j := range row {
+				row[j] = main.Columns[j][i]
+			}
+			numerator := PrimeField.Sub(gamma, compressRow(row, argument.A, alpha))
+			denominator := PrimeField.Sub(gamma, compressRow(row, argument.B, alpha))
+			if denominator.Equal(PrimeField.Zero()) {
+				panic("permutation challenge is a root of the running product")
+			}
+			z[i+1] = PrimeField.Div(PrimeField.Mul(z[i], numerator), denominator)
+		}
+		columns[k] = z
+	}
+	return NewTrace(columns...)
+}
+
+// TransitionDegrees returns the AIR's degrees followed by the running
+// products' degrees
+func (p *permutationAIR) TransitionDegrees() []int {
+
+	degrees := append([]int(nil), p.AIR.TransitionDegrees()...)
+	for range p.arguments {
+		degrees = append(degrees, 2)
+	}
+	return degrees
+}
+
+// EvaluateTransitions returns the AIR's transitions on the main segment
+// followed by the running products' transitions
+func (p *permutationAIR) EvaluateTransitions(frame Frame, challenges []ff.FieldElement) []ff.FieldElement {
+
+	main := Frame{Current: frame.Current[:p.width], Next: frame.Next[:p.width]}
+	transitions := p.AIR.EvaluateTransitions(main, nil)
+
+	alpha, gamma := challenges[0], challenges[1]
+	for k, argument := range p.arguments {
+		z, next := frame.Current[p.width+k], frame.Next[p.width+k]
+		a := PrimeField.Sub(gamma, compressRow(frame.Current, argument.A, alpha))
+		b := PrimeField.Sub(gamma, compressRow(frame.Current, argument.B, alpha))
+		transitions = append(transitions, PrimeField.Sub(PrimeField.Mul(next, b), PrimeField.Mul(z, a)))
+	}
+	return transitions
+}
+
+// BoundaryConstraints returns the AIR's boundary constraints followed by the
+// running products' first values
+func (p *permutationAIR) BoundaryConstraints(challenges []ff.FieldElement) []BoundaryConstraint {
+
+	constraints := append([]BoundaryConstraint(nil), p.AIR.BoundaryConstraints(nil)...)
+	for k := range p.arguments {
+		constraints = append(constraints, BoundaryConstraint{Column: p.width + k, Row: 0, Value: PrimeField.One()})
+	}
+	return constraints
+}
+
+// compressRow returns sum_j alpha^j row[columns[j]]
+func compressRow(row []ff.FieldElement, columns []int, alpha ff.FieldElement) ff.FieldElement {
+
+	result := PrimeField.Zero()
+	for j := len(columns) - 1; j >= 0; j-- {
+		result = PrimeField.Add(PrimeField.Mul(result, alpha), row[columns[j]])
+	}
+	return result
+}
@@ -0,0 +1,172 @@
+package zkstarks
+
+import (
+	"testing"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"github.com/stretchr/testify/assert"
+)
+
+// forgedProductAIR builds running products that wrap around to 1 whatever
+// the main segment by overwriting their last value.
+type forgedProductAIR struct {
+	WrappingAIR
+}
+
+func (air forgedProductAIR) AuxiliaryTrace(main *Trace, challenges []ff.FieldElement) *Trace {
+
+	aux := air.WrappingAIR.AuxiliaryTrace(main, challenges)
+	n := main.Length()
+	last := make([]ff.FieldElement, main.Width())
+	for j := range last {
+		last[j] = main.Columns[j][n-1]
+	}
+	alpha, gamma := challenges[0], challenges[1]
+	for k, argument := range air.WrappingAIR.(*permutationAIR).arguments {
+		a := PrimeField.Sub(gamma, compressRow(last, argument.A, alpha))
+		b := PrimeField.Sub(gamma, compressRow(last, argument.B, alpha))
+		aux.Columns[k][n-1] = PrimeField.Div(b, a)
+	}
+	return aux
+}
+
+// fixedChallengesAIR builds the auxiliary segment with the given challenges
+// instead of the ones drawn from the transcript.
+type fixedChallengesAIR struct {
+	AIR
+	challenges []ff.FieldElement
+}
+
+func (air fixedChallengesAIR) AuxiliaryTrace(main *Trace, _ []ff.FieldElement) *Trace {
+	return air.AIR.AuxiliaryTrace(main, air.challenges)
+}
+
+func TestPermutationArgument(t *testing.T) {
+
+	_, domain, _, _ := testFRIInstance(64, 0)
+	opts := DefaultProofOptions()
+	opts.NumQueries = 8
+
+	// shuffledTrace appends to the fibonacci columns a, b the rows (a, b)
+	// in the order of the permutation
+	shuffledTrace := func(permutation []int) *Trace {
+		fib := fibonacciTrace(8)
+		c := make([]ff.FieldElement, 8)
+		d := make([]ff.FieldElement, 8)
+		for i, j := range permutation {
+			c[i], d[i] = fib.Columns[0][j], fib.Columns[1][j]
+		}
+		return NewTrace(fib.Columns[0], fib.Columns[1], c, d)
+	}
+	permutation := []int{3, 7, 0, 5, 1, 6, 2, 4}
+	air := WithPermutations(fibonacciAIR{n: 8}, 4,
+		PermutationArgument{A: []int{0, 1}, B: []int{2, 3}},
+		PermutationArgument{A: []int{1}, B: []int{3}},
+	)
+
+	t.Run("TestRunningProduct", func(t *testing.T) {
+		rt := commitRAPTrace(air, shuffledTrace(permutation), domain, NewProverTranscript(NewSHA3Hasher()), opts)
+		assert.Len(t, rt.Challenges, 2)
+		assert.Equal(t, 2, rt.Auxiliary.Width())
+		assert.Equal(t, []int{1, 1, 7, 7, 7, 7, 7, 7}, QuotientDegreeBounds(air, rt.Challenges))
+
+		// the running products wrap around to 1
+		alpha, gamma := rt.Challenges[0], rt.Challenges[1]
+		last := make([]ff.FieldElement, 4)
+		for j := range last {
+			last[j] = rt.Main.Columns[j][7]
+		}
+		z := rt.Auxiliary.Columns[0]
+		a := PrimeField.Sub(gamma, compressRow(last, []int{0, 1}, alpha))
+		b := PrimeField.Sub(gamma, compressRow(last, []int{2, 3}, alpha))
+		assert.True(t, PrimeField.Mul(z[7], a).Equal(b))
+	})
+	t.Run("TestProveAndVerify", func(t *testing.T) {
+		for _, permutation := range [][]int{permutation, {0, 1, 2, 3, 4, 5, 6, 7}} {
+			var commitments *RAPCommitments
+			_, proof := proveTranscript(func(tr Transcript) []byte {
+				commitments = ProveRAP(air, shuffledTrace(permutation), domain, tr, opts)
+				return nil
+			})
+			assert.NotNil(t, commitments.Auxiliary)
+
+			err := verifyTranscript(proof, func(tr Transcript) error {
+				read, err := VerifyRAP(air, domain, tr, opts)
+				if err == nil {
+					assert.True(t, read.Equal(commitments))
+				}
+				return err
+			})
+			assert.NoError(t, err)
+		}
+	})
+	t.Run("TestRejectNonPermutation", func(t *testing.T) {
+		// a row of the first group is repeated in the second one
+		proof := proveAIR(air, shuffledTrace([]int{3, 7, 0, 5, 1, 6, 2, 2}), domain, opts)
+		assert.Error(t, verifyAIR(air, proof, domain, opts))
+
+		// the rows of each column are permuted but not the pairs
+		trace := shuffledTrace(permutation)
+		trace.Columns[3][0], trace.Columns[3][1] = trace.Columns[3][1], trace.Columns[3][0]
+		proof = proveAIR(air, trace, domain, opts)
+		assert.Error(t, verifyAIR(air, proof, domain, opts))
+	})
+	t.Run("TestRejectForgedRunningProduct", func(t *testing.T) {
+		// the running products of a non permutation wrap around to 1 but
+		// break the transition before the last row
+		main := shuffledTrace([]int{3, 7, 0, 5, 1, 6, 2, 2})
+		forged := forgedProductAIR{air}
+		rt := commitRAPTrace(forged, main, domain, NewProverTranscript(NewSHA3Hasher()), opts)
+		columns := append(append([][]ff.FieldElement(nil), rt.Main.Columns...), rt.Auxiliary.Columns...)
+		last := Frame{Current: make([]ff.FieldElement, len(columns)), Next: make([]ff.FieldElement, len(columns))}
+		for j, column := range columns {
+			last.Current[j], last.Next[j] = column[7], column[0]
+		}
+		for _, value := range air.EvaluateTransitions(last, rt.Challenges)[2:] {
+			assert.True(t, value.Equal(PrimeField.Zero()))
+		}
+
+		proof := proveAIR(forged, main, domain, opts)
+		assert.Error(t, verifyAIR(air, proof, domain, opts))
+	})
+	t.Run("TestRejectForgedComposition", func(t *testing.T) {
+		// the prover commits to the forged running products and to a
+		// composition of low degree which isn't the one of the trace
+		main := shuffledTrace([]int{3, 7, 0, 5, 1, 6, 2, 2})
+		_, _, composition, _ := testFRIInstance(64, 5, 4, 3, 2, 1)
+		_, proof := proveTranscript(func(tr Transcript) []byte {
+			rt := commitRAPTrace(forgedProductAIR{air}, main, domain, tr, opts)
+			rt.proveComposition(composition, drawCompositionCoefficients(air, rt.Challenges, tr), tr, opts)
+			return nil
+		})
+		assert.Error(t, verifyAIR(air, proof, domain, opts))
+
+		// as does a prover committing to the composition of a valid trace
+		_, proof = proveTranscript(func(tr Transcript) []byte {
+			rt := commitRAPTrace(forgedProductAIR{air}, main, domain, tr, opts)
+			coefficients := drawCompositionCoefficients(air, rt.Challenges, tr)
+			// the valid trace's running products are built for the
+			// challenges drawn from the forged commitments
+			valid := CommitRAPTrace(fixedChallengesAIR{air, rt.Challenges}, shuffledTrace(permutation), domain, NewChannel(), opts)
+			valid.Challenges = rt.Challenges
+			rt.proveComposition(valid.CompositionEvaluations(coefficients), coefficients, tr, opts)
+			return nil
+		})
+		assert.Error(t, verifyAIR(air, proof, domain, opts))
+	})
+	t.Run("TestBadArguments", func(t *testing.T) {
+		assert.Panics(t, func() { WithPermutations(fibonacciAIR{n: 8}, 4) })
+		assert.Panics(t, func() {
+			WithPermutations(fibonacciAIR{n: 8}, 4, PermutationArgument{A: []int{0, 1}, B: []int{2}})
+		})
+		assert.Panics(t, func() {
+			WithPermutations(fibonacciAIR{n: 8}, 4, PermutationArgument{A: []int{0}, B: []int{4}})
+		})
+		assert.Panics(t, func() {
+			WithPermutations(fibonacciAIR{n: 8, auxiliary: true}, 4, PermutationArgument{A: []int{0}, B: []int{2}})
+		})
+		assert.Panics(t, func() {
+			WithPermutations(fibonacciAIR{n: 8}, 1, PermutationArgument{A: []int{0}, B: []int{0}})
+		})
+	})
+}
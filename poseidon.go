@@ -0,0 +1,174 @@
+package zkstarks
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/actuallyachraf/algebra/ff"
+)
+
+// Poseidon (https://eprint.iacr.org/2019/458) is a substitution-permutation
+// network over F_p^t, each round adds round constants to the state, applies
+// the S-box x -> x^alpha and multiplies the state by an MDS matrix.
+// Full rounds apply the S-box to every element while partial rounds only
+// apply it to the first one, R_F/2 full rounds are done before and after
+// the R_P partial rounds.
+// The number of rounds is computed for any prime field using the bounds
+// of the reference round number script (statistical, interpolation and
+// Groebner basis attacks) with the same security margin of 2 full rounds
+// and 7.5% partial rounds.
+// Round constants are derived from SHAKE256 and the MDS matrix is a Cauchy
+// matrix, both are deterministic functions of the field and the width, they
+// are not the constants produced by the reference Grain LFSR.
+
+const poseidonName = "poseidon"
+
+// PoseidonParams represents an instance of the Poseidon permutation
+type PoseidonParams struct {
+	Field          ff.FiniteField
+	Width          int
+	Alpha          *big.Int
+	FullRounds     int
+	PartialRounds  int
+	RoundConstants [][]ff.FieldElement
+	MDS            [][]ff.FieldElement
+}
+
+// NewPoseidonParams generates the Poseidon parameters of the given width
+// over the given field.
+func NewPoseidonParams(field ff.FiniteField, width int) *PoseidonParams {
+
+	alpha := sboxExponent(field)
+	fullRounds, partialRounds := poseidonRoundNumbers(field, width, alpha.Int64())
+
+	rounds := fullRounds + partialRounds
+	seed := fmt.Sprintf("Poseidon(%s,%d,%d,%d,%d)", field.Modulus().String(), width, alpha.Int64(), fullRounds, partialRounds)
+	constants := shakeFieldElements(field, seed, rounds*width)
+
+	roundConstants := make([][]ff.FieldElement, rounds)
+	for r := range roundConstants {
+		roundConstants[r] = constants[r*width : (r+1)*width]
+	}
+
+	return &PoseidonParams{
+		Field:          field,
+		Width:          width,
+		Alpha:          alpha,
+		FullRounds:     fullRounds,
+		PartialRounds:  partialRounds,
+		RoundConstants: roundConstants,
+		MDS:            cauchyMatrix(field, width),
+	}
+}
+
+// Permute applies the Poseidon permutation to the state in place
+func (p *PoseidonParams) Permute(state []ff.FieldElement) {
+
+	if len(state) != p.Width {
+		panic("poseidon state has the wrong width")
+	}
+
+	halfFull := p.FullRounds / 2
+	for r := 0; r < p.FullRounds+p.PartialRounds; r++ {
+		for i := range state {
+			state[i] = p.Field.Add(state[i], p.RoundConstants[r][i])
+		}
+		if r < halfFull || r >= halfFull+p.PartialRounds {
+			for i := range state {
+				state[i] = state[i].Exp(p.Alpha)
+			}
+		} else {
+			state[0] = state[0].Exp(p.Alpha)
+		}
+		applyMatrix(p.MDS, state)
+	}
+}
+
+// NewPoseidonHasher returns a sponge hasher over the given field
+// instantiated with the Poseidon permutation.
+func NewPoseidonHasher(field ff.FiniteField) *AlgebraicHasher {
+
+	rate, capacity := spongeDimensions(field)
+	params := NewPoseidonParams(field, rate+capacity)
+
+	return &AlgebraicHasher{
+		name:     poseidonName,
+		field:    field,
+		rate:     rate,
+		capacity: capacity,
+		permute:  params.Permute,
+	}
+}
+
+// poseidonRoundNumbers returns the number of full and partial rounds
+// minimizing the number of S-boxes while satisfying the security bounds.
+func poseidonRoundNumbers(field ff.FiniteField, width int, alpha int64) (int, int) {
+
+	bestFull, bestPartial := 0, 0
+	bestCost := math.MaxInt64
+
+	for partial := 1; partial <= 500; partial++ {
+		for full := 2; full <= 100; full += 2 {
+			if !poseidonSecure(field, width, full, partial, alpha) {
+				continue
+			}
+			if cost := width*full + partial; cost < bestCost {
+				bestFull, bestPartial, bestCost = full, partial, cost
+			}
+			break
+		}
+	}
+
+	return bestFull + 2, int(math.Ceil(1.075 * float64(bestPartial)))
+}
+
+// poseidonSecure checks the bounds of the Poseidon round number script
+// for a security level of 128 bits.
+func poseidonSecure(field ff.FiniteField, width, full, partial int, alpha int64) bool {
+
+	m := float64(algebraicSecurityLevel)
+	t := float64(width)
+	rf := float64(full)
+	rp := float64(partial)
+	a := float64(alpha)
+
+	logp := float64(field.Modulus().BitLen()-1) + math.Log2(bigMantissa(field.Modulus()))
+	n := math.Ceil(logp)
+	logAlpha := func(x float64) float64 { return math.Log(x) / math.Log(a) }
+
+	statistical := 10.0
+	if m <= math.Floor(logp-(a-1)/2)*(t+1) {
+		statistical = 6
+	}
+	interpolation := 1 + math.Ceil(logAlpha(2)*math.Min(m, n)) + math.Ceil(logAlpha(t)) - rp
+	groebner1 := logAlpha(2)*math.Min(m, logp) - rp
+	groebner2 := t - 1 + logAlpha(2)*math.Min(m/(t+1), logp/2) - rp
+	groebner3 := (t - 2 + m/(2*math.Log2(a)) - rp) / (t - 1)
+
+	for _, bound := range []float64{statistical, interpolation, groebner1, groebner2, groebner3} {
+		if rf < math.Ceil(bound) {
+			return false
+		}
+	}
+
+	// https://eprint.iacr.org/2023/537.pdf
+	r := math.Floor(t / 3)
+	over := (rf-1)*t + rp + r + r*(rf/2) + rp + a
+	under := r*(rf/2) + rp + a
+	return math.Ceil(2*log2Binomial(over, under)) >= m
+}
+
+// bigMantissa returns x / 2^(bitlen(x)-1) which lies in [1,2)
+func bigMantissa(x *big.Int) float64 {
+	f, _ := new(big.Float).SetMantExp(new(big.Float).SetInt(x), -(x.BitLen() - 1)).Float64()
+	return f
+}
+
+// log2Binomial returns log2(n choose k)
+func log2Binomial(n, k float64) float64 {
+	ln, _ := math.Lgamma(n + 1)
+	lk, _ := math.Lgamma(k + 1)
+	lnk, _ := math.Lgamma(n - k + 1)
+	return (ln - lk - lnk) / math.Ln2
+}
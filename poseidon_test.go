@@ -0,0 +1,94 @@
+package zkstarks
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoseidon(t *testing.T) {
+
+	t.Run("TestParamGen", func(t *testing.T) {
+		rate, capacity := spongeDimensions(PrimeField)
+		assert.Equal(t, 8, rate)
+		assert.Equal(t, 8, capacity)
+
+		params := NewPoseidonParams(PrimeField, rate+capacity)
+		assert.Equal(t, int64(5), params.Alpha.Int64())
+		assert.Equal(t, 8, params.FullRounds)
+		assert.Equal(t, 14, params.PartialRounds)
+		assert.Len(t, params.RoundConstants, 22)
+
+		// over a 254 bit field a single element falls short of 256 bits
+		bn254, _ := new(big.Int).SetString("21888242871839275222246405745257275088548364400416034343698204186575808495617", 10)
+		field, _ := ff.NewFiniteField(bn254)
+		rate, capacity = spongeDimensions(field)
+		assert.Equal(t, 2, rate)
+		assert.Equal(t, 2, capacity)
+		params = NewPoseidonParams(field, rate+capacity)
+		assert.Equal(t, 8, params.FullRounds)
+		assert.Equal(t, 56, params.PartialRounds)
+	})
+	t.Run("TestPermutationDeterministic", func(t *testing.T) {
+		params := NewPoseidonParams(PrimeField, 16)
+		a := make([]ff.FieldElement, 16)
+		b := make([]ff.FieldElement, 16)
+		for i := range a {
+			a[i] = PrimeField.NewFieldElementFromInt64(int64(i))
+			b[i] = PrimeField.NewFieldElementFromInt64(int64(i))
+		}
+		b[15] = PrimeField.NewFieldElementFromInt64(16)
+		params.Permute(a)
+		params.Permute(b)
+		for i := range a {
+			assert.False(t, a[i].Equal(b[i]))
+		}
+	})
+	t.Run("TestHasher", func(t *testing.T) {
+		h := NewPoseidonHasher(PrimeField)
+		assert.Equal(t, "poseidon-3221225473", h.Name())
+
+		digest := h.Hash([]byte("abc"))
+		assert.Len(t, digest, 32)
+		assert.Equal(t, digest, h.Hash([]byte("ab"), []byte("c")))
+		assert.NotEqual(t, digest, h.Hash([]byte("abc"), []byte{0}))
+		assert.NotEqual(t, h.Hash([]byte{1}), h.Hash([]byte{0, 1}))
+		assert.Equal(t, "78c87908673bb0598e3ac4d65011510805340c1867be465654abb68ea68b08fd", hex.EncodeToString(digest))
+
+		named, err := HasherFromName(h.Name())
+		assert.NoError(t, err)
+		assert.Equal(t, digest, named.Hash([]byte("abc")))
+
+		for _, name := range []string{"poseidon-7", "poseidon-257", "rescue-prime-509"} {
+			_, err = HasherFromName(name)
+			assert.Error(t, err, name)
+		}
+		_, err = HasherFromName("poseidon-521")
+		assert.NoError(t, err)
+	})
+	t.Run("TestRejectSmallModulusHeader", func(t *testing.T) {
+		// the header is chosen by the prover, the verifier must reject it
+		// rather than hash with a field too small to pack bytes
+		proof := &Proof{Hash: "poseidon-7", Messages: []ProofMessage{{Label: "root", Data: []byte{1, 2, 3}}}}
+		assert.NotPanics(t, func() {
+			_, err := NewVerifierTranscript(proof)
+			assert.Error(t, err)
+		})
+	})
+	t.Run("TestMerkleAndChannel", func(t *testing.T) {
+		h := NewPoseidonHasher(PrimeField)
+		leaves := [][]byte{{1}, {2}, {3}, {4}}
+		tree := NewMerkleTreeWithHasher(leaves, 0, h)
+		auditPath, err := tree.Open(1)
+		assert.NoError(t, err)
+		assert.True(t, VerifyMerkleProof(h, tree.Root(), leaves[1], auditPath))
+
+		c := NewChannelWithHasher(h)
+		c.Send("root", tree.Root())
+		assert.Equal(t, "hash:poseidon-3221225473", c.Proof[0])
+		assert.Equal(t, h.Hash(sendTag, encodeLabel("root"), encodeLength(len(tree.Root())), tree.Root(), []byte{0}), c.State)
+	})
+}
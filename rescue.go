@@ -0,0 +1,120 @@
+package zkstarks
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/actuallyachraf/algebra/ff"
+)
+
+// Rescue-Prime (https://eprint.iacr.org/2020/1143) is a permutation over F_p^m
+// where each round is made of two steps :
+// - apply the S-box x -> x^alpha, multiply by the MDS matrix, add constants
+// - apply the inverse S-box x -> x^(1/alpha), multiply by the MDS matrix, add constants
+// The inverse S-box has high degree which lets Rescue use few rounds, the
+// number of rounds is computed as in the reference specification from the
+// cost of a Groebner basis attack with a 50% margin.
+// As for Poseidon round constants are derived with SHAKE256 and the MDS
+// matrix is a Cauchy matrix.
+
+const rescuePrimeName = "rescue-prime"
+
+// RescuePrimeParams represents an instance of the Rescue-Prime permutation
+type RescuePrimeParams struct {
+	Field          ff.FiniteField
+	Width          int
+	Capacity       int
+	Alpha          *big.Int
+	AlphaInv       *big.Int
+	Rounds         int
+	RoundConstants [][]ff.FieldElement
+	MDS            [][]ff.FieldElement
+}
+
+// NewRescuePrimeParams generates the Rescue-Prime parameters of the given
+// width and capacity over the given field.
+func NewRescuePrimeParams(field ff.FiniteField, width, capacity int) *RescuePrimeParams {
+
+	alpha := sboxExponent(field)
+	order := new(big.Int).Sub(field.Modulus(), big.NewInt(1))
+	alphaInv := new(big.Int).ModInverse(alpha, order)
+	rounds := rescuePrimeRounds(width, capacity, alpha.Int64())
+
+	seed := fmt.Sprintf("Rescue-XLIX(%s,%d,%d,%d)", field.Modulus().String(), width, capacity, algebraicSecurityLevel)
+	constants := shakeFieldElements(field, seed, 2*rounds*width)
+
+	roundConstants := make([][]ff.FieldElement, 2*rounds)
+	for r := range roundConstants {
+		roundConstants[r] = constants[r*width : (r+1)*width]
+	}
+
+	return &RescuePrimeParams{
+		Field:          field,
+		Width:          width,
+		Capacity:       capacity,
+		Alpha:          alpha,
+		AlphaInv:       alphaInv,
+		Rounds:         rounds,
+		RoundConstants: roundConstants,
+		MDS:            cauchyMatrix(field, width),
+	}
+}
+
+// Permute applies the Rescue-Prime permutation to the state in place
+func (p *RescuePrimeParams) Permute(state []ff.FieldElement) {
+
+	if len(state) != p.Width {
+		panic("rescue-prime state has the wrong width")
+	}
+
+	for r := 0; r < p.Rounds; r++ {
+		for step, exponent := range []*big.Int{p.Alpha, p.AlphaInv} {
+			for i := range state {
+				state[i] = state[i].Exp(exponent)
+			}
+			applyMatrix(p.MDS, state)
+			for i := range state {
+				state[i] = p.Field.Add(state[i], p.RoundConstants[2*r+step][i])
+			}
+		}
+	}
+}
+
+// NewRescuePrimeHasher returns a sponge hasher over the given field
+// instantiated with the Rescue-Prime permutation.
+func NewRescuePrimeHasher(field ff.FiniteField) *AlgebraicHasher {
+
+	rate, capacity := spongeDimensions(field)
+	params := NewRescuePrimeParams(field, rate+capacity, capacity)
+
+	return &AlgebraicHasher{
+		name:     rescuePrimeName,
+		field:    field,
+		rate:     rate,
+		capacity: capacity,
+		permute:  params.Permute,
+	}
+}
+
+// rescuePrimeRounds returns the number of rounds of the reference
+// specification : the smallest l such that a Groebner basis attack on
+// l rounds costs more than 2^128, with a minimum of 5 and 50% margin.
+func rescuePrimeRounds(width, capacity int, alpha int64) int {
+
+	rate := width - capacity
+	target := new(big.Int).Lsh(big.NewInt(1), algebraicSecurityLevel)
+
+	l := 1
+	for ; l < 25; l++ {
+		dcon := int64((alpha-1)*int64(width)*int64(l-1))/2 + 2
+		v := int64(width*(l-1) + rate)
+		cost := new(big.Int).Binomial(v+dcon, v)
+		if cost.Mul(cost, cost).Cmp(target) > 0 {
+			break
+		}
+	}
+	if l < 5 {
+		l = 5
+	}
+	return (3*l + 1) / 2
+}
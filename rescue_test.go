@@ -0,0 +1,55 @@
+package zkstarks
+
+import (
+	"testing"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"github.com/actuallyachraf/algebra/nt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRescuePrime(t *testing.T) {
+
+	t.Run("TestParamGen", func(t *testing.T) {
+		params := NewRescuePrimeParams(PrimeField, 16, 8)
+		assert.Equal(t, 8, params.Rounds)
+		assert.Len(t, params.RoundConstants, 16)
+
+		// x^(alpha*alphaInv) = x over the field
+		x := PrimeField.NewFieldElementFromInt64(31415)
+		assert.True(t, x.Exp(params.Alpha).Exp(params.AlphaInv).Equal(x))
+		assert.Equal(t, int64(1), nt.ModMul(params.Alpha, params.AlphaInv, nt.Sub(PrimeField.Modulus(), nt.FromInt64(1))).Int64())
+	})
+	t.Run("TestPermutationDiffers", func(t *testing.T) {
+		params := NewRescuePrimeParams(PrimeField, 16, 8)
+		state := make([]ff.FieldElement, 16)
+		for i := range state {
+			state[i] = PrimeField.Zero()
+		}
+		params.Permute(state)
+		zeros := 0
+		for _, x := range state {
+			if x.IsZero() {
+				zeros++
+			}
+		}
+		assert.Equal(t, 0, zeros)
+	})
+	t.Run("TestHasher", func(t *testing.T) {
+		h := NewRescuePrimeHasher(PrimeField)
+		assert.Equal(t, "rescue-prime-3221225473", h.Name())
+
+		digest := h.Hash([]byte("abc"))
+		assert.Len(t, digest, 32)
+		assert.NotEqual(t, NewPoseidonHasher(PrimeField).Hash([]byte("abc")), digest)
+
+		named, err := HasherFromName(h.Name())
+		assert.NoError(t, err)
+		assert.Equal(t, digest, named.Hash([]byte("abc")))
+
+		elems := []ff.FieldElement{PrimeField.NewFieldElementFromInt64(1), PrimeField.NewFieldElementFromInt64(2)}
+		assert.Len(t, h.HashElements(elems), 8)
+		_, err = HasherFromName("rescue-prime-3221225472")
+		assert.Error(t, err)
+	})
+}
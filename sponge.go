@@ -0,0 +1,212 @@
+package zkstarks
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"golang.org/x/crypto/sha3"
+)
+
+// Byte oriented hash functions are expensive to arithmetize, proving a
+// merkle path or a transcript inside another STARK requires a hash function
+// whose operations are native field operations.
+// Poseidon and Rescue-Prime are permutations over vectors of field elements
+// that we use in a sponge construction :
+// - the state is split in a rate part (first elements) and a capacity part
+// - inputs are absorbed rate elements at a time by adding them to the state
+// followed by a call to the permutation
+// - the digest is read from the rate part of the state.
+// The capacity is chosen so that it holds at least 256 bits, which gives
+// 128 bits of collision resistance regardless of the field size.
+
+// algebraicSecurityLevel is the security level in bits targeted by the
+// parameter generation of algebraic hash functions
+const algebraicSecurityLevel = 128
+
+// AlgebraicHasher is a sponge over a prime field instantiated with
+// an algebraic permutation, it implements Hasher over bytes and
+// exposes the native hash over field elements.
+type AlgebraicHasher struct {
+	name     string
+	field    ff.FiniteField
+	rate     int
+	capacity int
+	permute  func(state []ff.FieldElement)
+}
+
+// Name identifies the hash function and its field in the proof header
+func (h *AlgebraicHasher) Name() string {
+	return h.name + "-" + h.field.Modulus().String()
+}
+
+// Field returns the field the sponge operates on
+func (h *AlgebraicHasher) Field() ff.FiniteField {
+	return h.field
+}
+
+// HashElements absorbs the given field elements and returns a digest
+// of capacity elements.
+// The input is padded with a single 1 followed by zeros up to a multiple
+// of the rate so that inputs of different lengths don't collide.
+func (h *AlgebraicHasher) HashElements(elems []ff.FieldElement) []ff.FieldElement {
+
+	state := make([]ff.FieldElement, h.rate+h.capacity)
+	for i := range state {
+		state[i] = h.field.Zero()
+	}
+
+	padded := make([]ff.FieldElement, 0, len(elems)+h.rate)
+	padded = append(padded, elems...)
+	padded = append(padded, h.field.One())
+	for len(padded)%h.rate != 0 {
+		padded = append(padded, h.field.Zero())
+	}
+
+	for i := 0; i < len(padded); i += h.rate {
+		for j := 0; j < h.rate; j++ {
+			state[j] = h.field.Add(state[j], padded[i+j])
+		}
+		h.permute(state)
+	}
+
+	digest := make([]ff.FieldElement, h.capacity)
+	copy(digest, state[:h.capacity])
+	return digest
+}
+
+// Hash packs the concatenation of the inputs into field elements
+// and returns the serialized digest, each element holds as many
+// bytes as fit strictly below the modulus.
+func (h *AlgebraicHasher) Hash(data ...[]byte) []byte {
+
+	var msg []byte
+	for _, b := range data {
+		msg = append(msg, b...)
+	}
+
+	chunk := (h.field.Modulus().BitLen() - 1) / 8
+	elems := make([]ff.FieldElement, 0, len(msg)/chunk+1)
+	for i := 0; i < len(msg); i += chunk {
+		end := i + chunk
+		if end > len(msg) {
+			end = len(msg)
+		}
+		elems = append(elems, h.field.NewFieldElement(new(big.Int).SetBytes(msg[i:end])))
+	}
+	// the message length is absorbed so that leading zero bytes
+	// of the last chunk aren't lost
+	elems = append(elems, h.field.NewFieldElementFromInt64(int64(len(msg))))
+
+	return serializeFieldElements(h.HashElements(elems))
+}
+
+// spongeDimensions returns the rate and capacity of a sponge over
+// the given field.
+func spongeDimensions(field ff.FiniteField) (int, int) {
+
+	bits := field.Modulus().BitLen()
+	capacity := (2*algebraicSecurityLevel + bits - 1) / bits
+	rate := capacity
+	if rate < 2 {
+		rate = 2
+	}
+	return rate, capacity
+}
+
+// sboxExponent returns the smallest exponent alpha >= 3 such that
+// x -> x^alpha is a permutation of the field i.e gcd(alpha,p-1) = 1.
+func sboxExponent(field ff.FiniteField) *big.Int {
+
+	order := new(big.Int).Sub(field.Modulus(), big.NewInt(1))
+	alpha := big.NewInt(3)
+	for new(big.Int).GCD(nil, nil, alpha, order).Cmp(big.NewInt(1)) != 0 {
+		alpha.Add(alpha, big.NewInt(2))
+	}
+	return alpha
+}
+
+// shakeFieldElements derives n field elements from SHAKE256 seeded
+// with the given string using rejection sampling.
+func shakeFieldElements(field ff.FiniteField, seed string, n int) []ff.FieldElement {
+
+	shake := sha3.NewShake256()
+	shake.Write([]byte(seed))
+
+	bits := field.Modulus().BitLen()
+	buf := make([]byte, (bits+7)/8)
+	excess := uint(len(buf)*8 - bits)
+
+	elems := make([]ff.FieldElement, 0, n)
+	for len(elems) < n {
+		shake.Read(buf)
+		buf[0] &= 0xff >> excess
+		x := new(big.Int).SetBytes(buf)
+		if x.Cmp(field.Modulus()) < 0 {
+			elems = append(elems, field.NewFieldElement(x))
+		}
+	}
+	return elems
+}
+
+// cauchyMatrix returns the width x width matrix M[i][j] = 1/(i + width + j)
+// Cauchy matrices are MDS, every square submatrix is invertible.
+func cauchyMatrix(field ff.FiniteField, width int) [][]ff.FieldElement {
+
+	m := make([][]ff.FieldElement, width)
+	for i := range m {
+		m[i] = make([]ff.FieldElement, width)
+		for j := range m[i] {
+			m[i][j] = field.NewFieldElementFromInt64(int64(i + width + j)).Inv()
+		}
+	}
+	return m
+}
+
+// applyMatrix sets state to m.state
+func applyMatrix(m [][]ff.FieldElement, state []ff.FieldElement) {
+
+	field := state[0].Field()
+	result := make([]ff.FieldElement, len(state))
+	for i := range m {
+		acc := field.Zero()
+		for j := range state {
+			acc = field.Add(acc, field.Mul(m[i][j], state[j]))
+		}
+		result[i] = acc
+	}
+	copy(state, result)
+}
+
+// minAlgebraicModulusBits bounds the moduli accepted from hasher names, moduli
+// below 2^9 leave no room to pack message bytes into elements.
+const minAlgebraicModulusBits = 9
+
+// algebraicHasherFromName parses names of the form <hash>-<modulus>
+func algebraicHasherFromName(name string) (Hasher, error) {
+
+	idx := strings.LastIndex(name, "-")
+	if idx < 0 {
+		return nil, errors.New("unknown hash function")
+	}
+	modulus, ok := new(big.Int).SetString(name[idx+1:], 10)
+	if !ok || !modulus.ProbablyPrime(16) {
+		return nil, errors.New("unknown hash function")
+	}
+	// Hash packs (BitLen - 1) / 8 bytes per element, a forged header mustn't
+	// drive that to zero
+	if modulus.BitLen() <= minAlgebraicModulusBits {
+		return nil, fmt.Errorf("modulus %s is too small to pack bytes", modulus)
+	}
+	field, _ := ff.NewFiniteField(modulus)
+
+	switch name[:idx] {
+	case poseidonName:
+		return NewPoseidonHasher(field), nil
+	case rescuePrimeName:
+		return NewRescuePrimeHasher(field), nil
+	}
+	return nil, fmt.Errorf("unknown hash function %s", name[:idx])
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/actuallyachraf/algebra/ff"
 	"github.com/actuallyachraf/algebra/nt"
 	"github.com/actuallyachraf/algebra/poly"
-	"github.com/actuallyachraf/go-merkle"
 )
 
 // PrimeField in the remaining of the implementation we use a prime field
@@ -36,6 +35,7 @@ type DomainParameters struct {
 	PolynomialEvaluations []*big.Int        `json:"polynomial_evaluations"`
 	EvaluationRoot        []byte            `json:"evaluation_commitment"`
 }
+
 // Unpack returns the domain parameters as separate vars
 
 // JSONDomainParams encode values properly for safe serialization.
@@ -186,7 +186,7 @@ func (params *DomainParameters) UnmarshalJSON(b []byte) error {
 		params.PolynomialEvaluations[i] = elem
 	}
 
-	params.EvaluationRoot,_ = hex.DecodeString(jsonDomParams.EvaluationRoot)
+	params.EvaluationRoot, _ = hex.DecodeString(jsonDomParams.EvaluationRoot)
 
 	return nil
 }
@@ -247,6 +247,13 @@ func GenElems(generator ff.FieldElement, order int) []ff.FieldElement {
 // fEvalCommitmentRoot : merkle commitment of the evaluations of over H
 // fsChan : fiat shamir channel initiated with the commitment root
 func GenerateDomainParameters() ([]ff.FieldElement, ff.FieldElement, []ff.FieldElement, ff.FieldElement, []ff.FieldElement, []ff.FieldElement, poly.Polynomial, []*big.Int, []byte, *Channel) {
+	return GenerateDomainParametersWithCap(0)
+}
+
+// GenerateDomainParametersWithCap is GenerateDomainParameters where the
+// evaluations are committed by a merkle cap of height capHeight, the returned
+// commitment is the serialized cap (the root for a cap of height 0).
+func GenerateDomainParametersWithCap(capHeight int) ([]ff.FieldElement, ff.FieldElement, []ff.FieldElement, ff.FieldElement, []ff.FieldElement, []ff.FieldElement, poly.Polynomial, []*big.Int, []byte, *Channel) {
 	a := GenSeq()
 	g := PrimeFieldGen.Exp(new(big.Int).SetInt64(3145728))
 	G := GenElems(g, 1024)
@@ -283,11 +290,11 @@ func GenerateDomainParameters() ([]ff.FieldElement, ff.FieldElement, []ff.FieldE
 	// values, hash functions are the most elementary of such protocols.
 	// When committing to a range of values a more efficient way to do
 	// so is to use merkle trees.
-	commitmentRoot := merkle.Root(cosetEvalBytes)
+	commitmentRoot := NewMerkleTreeWithCap(cosetEvalBytes, capHeight).Commitment()
 	fmt.Println("Commitment to Coset Evaluation :", hex.EncodeToString(commitmentRoot))
 
 	fsChan := NewChannel()
-	fsChan.Send(commitmentRoot)
+	fsChan.Send("trace.commitment", commitmentRoot)
 
 	return a, g, G, hGenerator, H, evalDomain, f, cosetEval, commitmentRoot, fsChan
 
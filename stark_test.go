@@ -46,7 +46,7 @@ func TestZKGen(t *testing.T) {
 	}
 	_, g, _, _, _, _, f, _, _ := paramsInstance.Trace, paramsInstance.GeneratorG, paramsInstance.SubgroupG, paramsInstance.GeneratorH, paramsInstance.SubgroupH, paramsInstance.EvaluationDomain, paramsInstance.Polynomial, paramsInstance.PolynomialEvaluations, paramsInstance.EvaluationRoot
 	fsChannel := NewChannel()
-	fsChannel.Send(paramsInstance.EvaluationRoot)
+	fsChannel.Send("trace.commitment", paramsInstance.EvaluationRoot)
 	t.Run("TestParamGen", func(t *testing.T) {
 		t.Log("Trace length :", len(paramsInstance.Trace))
 		t.Log("Subgroup G generator :", paramsInstance.GeneratorG)
@@ -78,21 +78,30 @@ func TestZKGen(t *testing.T) {
 		}
 
 		// To generate succint proofs we transform the three polynomial validity checks
-		// into one by applying a linear transform [a0,a1,a2]
-		// the composition polynomial is written a0p0 + a1p1 + a2p2
-		// where a0,a1,a2 are random field elements in this case extracted
-		// from the fiat shamir channel
+		// into one by applying a linear transform, since the quotients have
+		// different degree bounds d_i each is adjusted to the composition's
+		// bound D and the composition polynomial is written
+		// sum_i (a_i + b_i X^(D - d_i)) p_i where a_i,b_i are random field
+		// elements in this case extracted from the fiat shamir channel
 
 		constraints := []poly.Polynomial{quoPolyConstraint1, quoPolyConstraint2, quoPolyConstraint3}
-		compositionPoly := poly.NewPolynomialInts(0)
-		for i := 0; i < 3; i++ {
-
-			randomFE := fsChannel.RandFE(PrimeField.Modulus())
-			comb := constraints[i].Mul(poly.NewPolynomialBigInt(randomFE), PrimeField.Modulus())
-			compositionPoly = compositionPoly.Add(comb, PrimeField.Modulus())
+		degreeBounds := []int{
+			QuotientDegreeBound(1, len(f), 1),
+			QuotientDegreeBound(1, len(f), 1),
+			QuotientDegreeBound(2, len(f), len(paramsInstance.SubgroupG)-3),
+		}
+		randomFEs := fsChannel.RandFEs("composition.coefficient", 2*len(constraints), PrimeField.Modulus())
+		coefficients := make([]ff.FieldElement, len(randomFEs))
+		for i, randomFE := range randomFEs {
+			coefficients[i] = PrimeField.NewFieldElement(randomFE)
 		}
+		compositionPoly := DegreeAdjustedComposition(constraints, degreeBounds, coefficients)
+		assert.True(t, len(compositionPoly) <= CompositionDegreeBound(degreeBounds))
 		t.Log("Composition Polynomial :", compositionPoly)
 
+		// The composition is committed unsplit as the channel proof always
+		// did, TestProveSplitComposition commits it as segments instead
+
 		// Now we evaluate the composition polynomial on the evaluation domain
 		// and commit to the evaluation
 		compositionPolyEvals := make([]ff.FieldElement, len(paramsInstance.EvaluationDomain))
@@ -101,36 +110,86 @@ func TestZKGen(t *testing.T) {
 			eval := compositionPoly.Eval(elem.Big(), PrimeField.Modulus())
 			compositionPolyEvals[idx] = PrimeField.NewFieldElement(eval)
 		}
-		compositionPolyEvalsRoot := DomainHash(compositionPolyEvals)
+		compositionPolyEvalsTree := CommitFRILayer(compositionPolyEvals, 2, 0, NewSHA3Hasher())
+		compositionPolyEvalsRoot := compositionPolyEvalsTree.Commitment()
 
 		t.Log("Composition Polynomial Evaluations Root :", hex.EncodeToString(compositionPolyEvalsRoot))
-		fsChannel.Send(compositionPolyEvalsRoot)
+		fsChannel.Send("composition.commitment", compositionPolyEvalsRoot)
 
-		friDomains, friPolys, friLayers, friRoots := GenerateFRICommitment(compositionPoly, paramsInstance.EvaluationDomain, compositionPolyEvals, compositionPolyEvalsRoot, *fsChannel)
+		friDomains, friLayers, friTrees := GenerateFRICommitment(len(compositionPoly), paramsInstance.EvaluationDomain, compositionPolyEvals, compositionPolyEvalsTree, fsChannel)
 
 		assert.Len(t, friLayers, 11)
 		assert.Len(t, friLayers[len(friLayers)-1], 8)
-		expectedLastLayerConstant := PrimeField.NewFieldElementFromInt64(2550486681)
+		expectedLastLayerConstant := PrimeField.NewFieldElementFromInt64(2769201988)
 		for _, x := range friLayers[len(friLayers)-1] {
 			assert.True(t, x.Equal(expectedLastLayerConstant))
 		}
 
-		assert.Equal(t, friPolys[len(friPolys)-1].Degree(), 0)
-
 		t.Log("FRI-Layer Count :", len(friLayers))
-		t.Log("FRI-Root Count", len(friRoots))
+		t.Log("FRI-Root Count", len(friTrees))
 		t.Log("FRI Domains Count :", len(friDomains))
-		t.Log("Last Layer Root :", hex.EncodeToString(friRoots[len(friRoots)-1]))
+		t.Log("Last Committed Layer Root :", hex.EncodeToString(friTrees[len(friTrees)-1].Root()))
 		t.Log("Last Layer Terms")
 		for _, x := range friLayers[len(friLayers)-1] {
 			t.Log("x = ", x.String())
 		}
 		t.Log("Channel Proof", fsChannel.Proof)
 
-		cosetEvals := paramsInstance.PolynomialEvaluations
-		FRIDecommit(fsChannel, cosetEvals, friLayers)
+		cosetTree := NewMerkleTree(cosetDomainBytes(paramsInstance.PolynomialEvaluations))
+		assert.Equal(t, cosetTree.Root(), paramsInstance.EvaluationRoot)
+		FRIDecommit(fsChannel, cosetTree, friTrees)
 
 		t.Log("Final Proof Uncompressed", fsChannel.Proof)
 	})
+	t.Run("TestProveSplitComposition", func(t *testing.T) {
+		quotients := make([]poly.Polynomial, 3)
+		quotients[0], quotients[1], quotients[2] = GenerateProgramConstraints(f.Clone(0), g)
+		degreeBounds := []int{
+			QuotientDegreeBound(1, len(f), 1),
+			QuotientDegreeBound(1, len(f), 1),
+			QuotientDegreeBound(2, len(f), len(paramsInstance.SubgroupG)-3),
+		}
+		drawCoefficients := func(tr Transcript) []ff.FieldElement {
+			coefficients := make([]ff.FieldElement, 2*len(quotients))
+			for i, randomFE := range tr.RandFEs("composition.coefficient", len(coefficients), PrimeField.Modulus()) {
+				coefficients[i] = PrimeField.NewFieldElement(randomFE)
+			}
+			return coefficients
+		}
+
+		// the composition is split into segments of a quarter of its degree
+		// bound, the prover commits to them and proves they are of low degree
+		numSegments := 4
+		segmentBound := CompositionDegreeBound(degreeBounds) / numSegments
+		opts := DefaultProofOptions()
+		opts.NumQueries = 8
+		commitment, proof := proveTranscript(func(tr Transcript) []byte {
+			proverMessage(tr, "trace.commitment", paramsInstance.EvaluationRoot)
+			compositionPoly := DegreeAdjustedComposition(quotients, degreeBounds, drawCoefficients(tr))
+			assert.Len(t, SplitComposition(compositionPoly, segmentBound), numSegments)
+			return ProveComposition(compositionPoly, numSegments, segmentBound, paramsInstance.EvaluationDomain, tr, opts)
+		})
+
+		// the verifier recombines the segments at the out of domain point
+		// and checks the value against the quotients
+		err := verifyTranscript(proof, func(tr Transcript) error {
+			if _, err := tr.Message("trace.commitment", nil); err != nil {
+				return err
+			}
+			coefficients := drawCoefficients(tr)
+			openings, err := VerifyComposition(commitment, numSegments, segmentBound, paramsInstance.EvaluationDomain, tr, opts)
+			if err != nil {
+				return err
+			}
+			values := make([]ff.FieldElement, len(quotients))
+			for i, quotient := range quotients {
+				values[i] = PrimeField.NewFieldElement(quotient.Eval(openings.Point.Big(), PrimeField.Modulus()))
+			}
+			assert.True(t, DegreeAdjustedEvaluation(openings.Point, values, degreeBounds, coefficients).Equal(openings.Value))
+			return nil
+		})
+		assert.NoError(t, err)
+		t.Log("Composition Segments :", numSegments, "Proof Messages :", len(proof.Messages))
+	})
 
 }
@@ -0,0 +1,342 @@
+package zkstarks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/actuallyachraf/algebra/ff"
+	"github.com/actuallyachraf/algebra/poly"
+)
+
+// STIR (Arnon, Chiesa, Fenzi, Yogev) folds by the factor k in each round as
+// FRI does but the domain only shrinks by 2 so the rate improves from round
+// to round and each round needs fewer queries.
+// A round starts from a function f on the domain L of degree bound d :
+// - the verifier draws r_fold, the fold g of f by r_fold (of degree bound
+// d/k) is committed as its evaluations g' over the next domain L' which
+// is the odd half of L in natural order (L' is disjoint from L^k)
+// - an out of domain point r_out is drawn and the prover answers g(r_out)
+// - shift queries are drawn in L^k, the verifier computes the fold of f at
+// each of them from the block opened in the commitment of f
+// - the next function is the quotient of g' by the points S of the out of
+// domain and shift answers, multiplied by sum_e (r_comb x)^e for e up to |S|
+// so that its degree bound is exactly d/k, f' = DegCor(Quotient(g', S)).
+// The verifier never sees f' : it computes it from g' at the points it opens.
+// Once the degree bound is small enough the prover sends the coefficients of
+// the fold and the shift queries are checked against them.
+// A query on a function of rate rho gives about log2(1/rho) bits, NumQueries
+// sets the security at the initial rate and each round draws the fewest
+// queries reaching it at its own rate, so later rounds draw fewer queries.
+// Rounds must keep more than |S| coefficients (at most NumQueries + 1), the
+// final polynomial has at most max(RemainderDegreeBound, NumQueries + 1).
+
+// STIRProve proves that the evaluations over the domain (given in its natural
+// order) are of a polynomial with less than degreeBound coefficients and
+// returns the commitment to the evaluations.
+func STIRProve(evals []ff.FieldElement, domain []ff.FieldElement, degreeBound int, tr Transcript, opts ProofOptions) []byte {
+
+	rounds, err := stirRounds(len(domain), degreeBound, opts)
+	if err != nil {
+		panic(err)
+	}
+	if len(evals) != len(domain) {
+		panic("evaluations must be given over the domain")
+	}
+	factor := opts.FoldingFactor
+	queries := stirQueryCounts(len(domain), degreeBound, rounds, opts)
+
+	tree := CommitFRILayer(evals, factor, opts.CapHeight, tr.Hasher())
+	commitment := tree.Commitment()
+	proverMessage(tr, "stir.evaluations", commitment)
+
+	for i, nextBound := range rounds {
+		rFold := PrimeField.NewFieldElement(tr.RandFE("stir.fold", PrimeField.Modulus()))
+
+		reversedDomain := BitReverse(domain)
+		folded, _ := FoldFRILayerWithFactor(BitReverse(evals), batchInverse(reversedDomain), rFold, factor)
+		points := make([]ff.FieldElement, len(folded))
+		for j := range points {
+			points[j] = reversedDomain[j*factor].Exp(big.NewInt(int64(factor)))
+		}
+		g := RemainderPolynomial(points, folded, nextBound)
+
+		if i == len(rounds)-1 {
+			proverMessage(tr, "stir.final", encodeElements(tr.Hasher(), g))
+			openLeaves(tr, tree, stirShiftQueries(tr, len(folded), queries[i], opts), "stir.coset", "stir.multiproof")
+			break
+		}
+
+		nextDomain := stirNextDomain(domain)
+		nextEvals := make([]ff.FieldElement, len(nextDomain))
+		for m, x := range nextDomain {
+			nextEvals[m] = evalCoeffs(g, x)
+		}
+		nextTree := CommitFRILayer(nextEvals, factor, opts.CapHeight, tr.Hasher())
+		proverMessage(tr, "stir.commitment", nextTree.Commitment())
+
+		rOut := PrimeField.NewFieldElement(tr.RandFE("stir.ood", PrimeField.Modulus()))
+		proverMessage(tr, "stir.ood.answer", encodeElements(tr.Hasher(), []ff.FieldElement{evalCoeffs(g, rOut)}))
+		rComb := PrimeField.NewFieldElement(tr.RandFE("stir.comb", PrimeField.Modulus()))
+
+		shifts := stirShiftQueries(tr, len(folded), queries[i], opts)
+		openLeaves(tr, tree, shifts, "stir.coset", "stir.multiproof")
+
+		quotient := stirQuotient{points: []ff.FieldElement{rOut}, values: []ff.FieldElement{evalCoeffs(g, rOut)}, comb: rComb}
+		for _, j := range shifts {
+			quotient.points = append(quotient.points, points[j])
+			quotient.values = append(quotient.values, folded[j])
+		}
+		for m, x := range nextDomain {
+			nextEvals[m], err = quotient.apply(x, nextEvals[m])
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		domain, evals, tree = nextDomain, nextEvals, nextTree
+	}
+
+	return commitment
+}
+
+// STIRVerify verifies a proof produced by STIRProve that the evaluations
+// committed to by the commitment are of a polynomial with less than
+// degreeBound coefficients.
+func STIRVerify(commitment []byte, domain []ff.FieldElement, degreeBound int, tr Transcript, opts ProofOptions) error {
+
+	rounds, err := stirRounds(len(domain), degreeBound, opts)
+	if err != nil {
+		return err
+	}
+	factor := opts.FoldingFactor
+	queries := stirQueryCounts(len(domain), degreeBound, rounds, opts)
+
+	sent, err := tr.Message("stir.evaluations", nil)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(sent, commitment) {
+		return errors.New("proof doesn't commit to the given evaluations")
+	}
+
+	// quotient turns the committed g' of the round into its function f,
+	// it is nil in the first round where f is committed
+	var quotient *stirQuotient
+
+	for i, nextBound := range rounds {
+		rFold := PrimeField.NewFieldElement(tr.RandFE("stir.fold", PrimeField.Modulus()))
+
+		var final poly.Polynomial
+		var nextCommitment []byte
+		var next *stirQuotient
+		if i == len(rounds)-1 {
+			b, err := tr.Message("stir.final", nil)
+			if err != nil {
+				return err
+			}
+			coeffs, err := decodeElements(tr.Hasher(), b)
+			if err != nil || len(coeffs) != nextBound {
+				return errors.New("bad STIR final polynomial")
+			}
+			final = poly.NewPolynomial(coeffs)
+		} else {
+			nextCommitment, err = tr.Message("stir.commitment", nil)
+			if err != nil {
+				return err
+			}
+			rOut := PrimeField.NewFieldElement(tr.RandFE("stir.ood", PrimeField.Modulus()))
+			b, err := tr.Message("stir.ood.answer", nil)
+			if err != nil {
+				return err
+			}
+			answer, err := decodeElements(tr.Hasher(), b)
+			if err != nil || len(answer) != 1 {
+				return errors.New("bad STIR out of domain answer")
+			}
+			rComb := PrimeField.NewFieldElement(tr.RandFE("stir.comb", PrimeField.Modulus()))
+			next = &stirQuotient{points: []ff.FieldElement{rOut}, values: answer, comb: rComb}
+		}
+
+		shifts, err := stirReadShiftQueries(tr, len(domain)/factor, queries[i], opts)
+		if err != nil {
+			return err
+		}
+		leaves, err := readLeaves(tr, commitment, len(domain)/factor, opts.CapHeight, shifts, "stir.coset", "stir.multiproof")
+		if err != nil {
+			return fmt.Errorf("STIR round %d : %v", i, err)
+		}
+		for q, j := range shifts {
+			coset, err := decodeElements(tr.Hasher(), leaves[q])
+			if err != nil || len(coset) != factor {
+				return fmt.Errorf("bad coset in STIR round %d", i)
+			}
+
+			xs := make([]ff.FieldElement, factor)
+			for t := range xs {
+				xs[t] = domain[bitReverseIndex(j*factor+t, log2(len(domain)))]
+				if quotient != nil {
+					if coset[t], err = quotient.apply(xs[t], coset[t]); err != nil {
+						return err
+					}
+				}
+			}
+			point := xs[0].Exp(big.NewInt(int64(factor)))
+			value := interpolateAt(xs, coset, rFold)
+
+			if final != nil {
+				expected := PrimeField.NewFieldElement(final.Eval(point.Big(), PrimeField.Modulus()))
+				if !value.Equal(expected) {
+					return fmt.Errorf("STIR final polynomial doesn't match shift query %d", q)
+				}
+				continue
+			}
+			next.points = append(next.points, point)
+			next.values = append(next.values, value)
+		}
+
+		domain, commitment, quotient = stirNextDomain(domain), nextCommitment, next
+	}
+
+	return nil
+}
+
+// stirRounds checks the parameters and returns the degree bound of the fold
+// of each round.
+func stirRounds(size int, degreeBound int, opts ProofOptions) ([]int, error) {
+
+	factor := opts.FoldingFactor
+	if factor < 2 || factor&(factor-1) != 0 {
+		return nil, errors.New("folding factor must be a power of 2")
+	}
+	if size == 0 || size&(size-1) != 0 {
+		return nil, errors.New("domain size must be a power of 2")
+	}
+	if opts.NumQueries < 1 || opts.RemainderDegreeBound < 1 || degreeBound <= opts.RemainderDegreeBound {
+		return nil, errors.New("degree bound must exceed the remainder degree bound")
+	}
+
+	finalBound := opts.RemainderDegreeBound
+	if finalBound < opts.NumQueries+1 {
+		finalBound = opts.NumQueries + 1
+	}
+
+	var rounds []int
+	for bound := degreeBound; ; size /= 2 {
+		if size%factor != 0 || size/factor < (bound+factor-1)/factor {
+			return nil, errors.New("STIR domain is too small for the folding factor")
+		}
+		bound = (bound + factor - 1) / factor
+		rounds = append(rounds, bound)
+		if bound <= finalBound {
+			return rounds, nil
+		}
+	}
+}
+
+// stirQueryCounts returns the number of shift queries of each round, round
+// i queries the function of degree bound d_i over the domain L_i of size
+// |L| / 2^i and draws ceil(NumQueries * log2(|L|/d) / log2(|L_i|/d_i)).
+func stirQueryCounts(size int, degreeBound int, rounds []int, opts ProofOptions) []int {
+
+	securityBits := float64(opts.NumQueries) * math.Log2(float64(size)/float64(degreeBound))
+	counts := make([]int, len(rounds))
+	bound := degreeBound
+	for i := range rounds {
+		counts[i] = int(math.Ceil(securityBits / math.Log2(float64(size)/float64(bound))))
+		if counts[i] > opts.NumQueries {
+			counts[i] = opts.NumQueries
+		}
+		size, bound = size/2, rounds[i]
+	}
+	return counts
+}
+
+// stirNextDomain returns the odd half of the domain
+func stirNextDomain(domain []ff.FieldElement) []ff.FieldElement {
+
+	next := make([]ff.FieldElement, len(domain)/2)
+	for m := range next {
+		next[m] = domain[2*m+1]
+	}
+	return next
+}
+
+// stirShiftQueries draws the shift queries, duplicates are removed
+func stirShiftQueries(tr Transcript, size int, numQueries int, opts ProofOptions) []int {
+
+	if opts.GrindingBits > 0 {
+		grind(tr, opts.GrindingBits)
+	}
+	return stirQueryIndices(tr, size, numQueries)
+}
+
+// stirReadShiftQueries checks the grinding nonce if required and draws the
+// shift queries
+func stirReadShiftQueries(tr Transcript, size int, numQueries int, opts ProofOptions) ([]int, error) {
+
+	if opts.GrindingBits > 0 {
+		if err := readGrinding(tr, opts.GrindingBits); err != nil {
+			return nil, err
+		}
+	}
+	return stirQueryIndices(tr, size, numQueries), nil
+}
+
+// stirQueryIndices draws sorted and distinct indices in [0,size)
+func stirQueryIndices(tr Transcript, size int, numQueries int) []int {
+
+	seen := make(map[int]bool)
+	var indices []int
+	for _, index := range tr.RandInts("stir.shift", numQueries, big.NewInt(0), big.NewInt(int64(size-1))) {
+		if !seen[int(index.Int64())] {
+			seen[int(index.Int64())] = true
+			indices = append(indices, int(index.Int64()))
+		}
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// stirQuotient represents the quotient and degree correction of a round
+type stirQuotient struct {
+	points []ff.FieldElement
+	values []ff.FieldElement
+	comb   ff.FieldElement
+}
+
+// apply returns the next function at x given the committed value g'(x)
+// (g'(x) - Ans(x)) / V(x) * sum_{e<=|S|} (comb * x)^e
+func (q *stirQuotient) apply(x ff.FieldElement, value ff.FieldElement) (ff.FieldElement, error) {
+
+	vanishing := PrimeField.One()
+	for _, s := range q.points {
+		vanishing = PrimeField.Mul(vanishing, PrimeField.Sub(x, s))
+	}
+	if vanishing.Equal(PrimeField.Zero()) {
+		return vanishing, errors.New("STIR quotient point lies in the domain")
+	}
+	numerator := PrimeField.Sub(value, interpolateAt(q.points, q.values, x))
+
+	correction, term := PrimeField.One(), PrimeField.One()
+	rx := PrimeField.Mul(q.comb, x)
+	for range q.points {
+		term = PrimeField.Mul(term, rx)
+		correction = PrimeField.Add(correction, term)
+	}
+
+	return PrimeField.Mul(PrimeField.Div(numerator, vanishing), correction), nil
+}
+
+// evalCoeffs evaluates the polynomial with the given coefficients at x
+func evalCoeffs(coeffs []ff.FieldElement, x ff.FieldElement) ff.FieldElement {
+
+	result := PrimeField.Zero()
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		result = PrimeField.Add(PrimeField.Mul(result, x), coeffs[i])
+	}
+	return result
+}
@@ -0,0 +1,135 @@
+package zkstarks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSTIR(t *testing.T) {
+
+	// testCoeffs returns the coefficients of a polynomial with degreeBound
+	// coefficients
+	testCoeffs := func(degreeBound int) []int {
+		coeffs := make([]int, degreeBound)
+		for i := range coeffs {
+			coeffs[i] = i + 1
+		}
+		return coeffs
+	}
+	prove := func(test LowDegreeTest, size int64, degreeBound int, coeffs ...int) ([]byte, *Proof) {
+		_, domain, evals, _ := testFRIInstance(size, coeffs...)
+		return proveTranscript(func(tr Transcript) []byte {
+			return test.Prove(evals, domain, degreeBound, tr)
+		})
+	}
+	verify := func(test LowDegreeTest, commitment []byte, proof *Proof, size int64, degreeBound int) error {
+		_, domain, _, _ := testFRIInstance(size, 0)
+		return verifyTranscript(proof, func(tr Transcript) error {
+			return test.Verify(commitment, domain, degreeBound, tr)
+		})
+	}
+	stir := func(factor, queries int) LowDegreeTest {
+		opts := DefaultProofOptions()
+		opts.ProximityTest = STIRProximityTest
+		opts.FoldingFactor, opts.NumQueries = factor, queries
+		test, err := NewLowDegreeTest(opts)
+		if err != nil {
+			panic(err)
+		}
+		return test
+	}
+
+	t.Run("TestRounds", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.FoldingFactor = 4
+		rounds, err := stirRounds(256, 64, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{16, 4}, rounds)
+
+		opts.FoldingFactor = 2
+		rounds, err = stirRounds(64, 16, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{8, 4}, rounds)
+
+		_, err = stirRounds(16, 64, opts)
+		assert.Error(t, err)
+	})
+	t.Run("TestNextDomain", func(t *testing.T) {
+		_, domain, _, _ := testFRIInstance(64, 0)
+		next := stirNextDomain(domain)
+		assert.Len(t, next, 32)
+		for _, x := range next {
+			for _, y := range NextFRIDomain(domain) {
+				assert.False(t, x.Equal(y))
+			}
+		}
+	})
+	t.Run("TestProveAndVerify", func(t *testing.T) {
+		for _, factor := range []int{2, 4} {
+			test := stir(factor, 3)
+			commitment, proof := prove(test, 64, 16, testCoeffs(16)...)
+			assert.NoError(t, verify(test, commitment, proof, 64, 16), "factor %d", factor)
+
+			commitment, proof = prove(test, 256, 64, testCoeffs(64)...)
+			assert.NoError(t, verify(test, commitment, proof, 256, 64), "factor %d", factor)
+		}
+	})
+	t.Run("TestGrinding", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.ProximityTest, opts.GrindingBits = STIRProximityTest, 4
+		test, err := NewLowDegreeTest(opts)
+		assert.NoError(t, err)
+		commitment, proof := prove(test, 64, 16, testCoeffs(16)...)
+		assert.NoError(t, verify(test, commitment, proof, 64, 16))
+	})
+	t.Run("TestRejectHighDegree", func(t *testing.T) {
+		for _, factor := range []int{2, 4} {
+			test := stir(factor, 6)
+			commitment, proof := prove(test, 256, 64, testCoeffs(65)...)
+			assert.Error(t, verify(test, commitment, proof, 256, 64), "factor %d", factor)
+		}
+	})
+	t.Run("TestRejectTamperedProof", func(t *testing.T) {
+		test := stir(2, 3)
+		commitment, proof := prove(test, 64, 16, testCoeffs(16)...)
+		assertRejectsTamperedMessages(t, proof, func(proof *Proof) error {
+			return verify(test, commitment, proof, 64, 16)
+		}, "stir.evaluations", "stir.commitment", "stir.ood.answer", "stir.coset", "stir.multiproof", "stir.final")
+	})
+	t.Run("TestQueryCounts", func(t *testing.T) {
+		opts := DefaultProofOptions()
+		opts.FoldingFactor, opts.NumQueries = 4, 8
+		// rates 1/4 then 1/8 : 16 bits need 8 then 6 queries
+		assert.Equal(t, []int{8, 6}, stirQueryCounts(256, 64, []int{16, 4}, opts))
+
+		opts.NumQueries = 16
+		rounds, err := stirRounds(4096, 256, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{64, 16}, rounds)
+		assert.Equal(t, []int{16, 13}, stirQueryCounts(4096, 256, rounds, opts))
+	})
+	t.Run("TestCompareWithFRI", func(t *testing.T) {
+		sizes := make(map[string]int)
+		for _, name := range []string{FRIProximityTest, STIRProximityTest} {
+			opts := DefaultProofOptions()
+			opts.ProximityTest, opts.FoldingFactor, opts.NumQueries = name, 4, 16
+			test, err := NewLowDegreeTest(opts)
+			assert.NoError(t, err)
+			assert.Equal(t, name, test.Name())
+
+			commitment, proof := prove(test, 4096, 256, testCoeffs(256)...)
+			assert.NoError(t, verify(test, commitment, proof, 4096, 256))
+			for _, msg := range proof.Messages {
+				sizes[name] += len(msg.Data)
+			}
+			t.Logf("%s proof : %d messages %d bytes", name, len(proof.Messages), sizes[name])
+		}
+		assert.Less(t, sizes[STIRProximityTest], sizes[FRIProximityTest])
+
+		opts := DefaultProofOptions()
+		opts.ProximityTest = "whir"
+		_, err := NewLowDegreeTest(opts)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,184 @@
+package zkstarks
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Merlin transcripts are built on STROBE-128 a framework for symmetric
+// protocols based on the Keccak-f[1600] sponge (https://strobe.sourceforge.io).
+// Merlin only uses a subset of STROBE's operations :
+// - meta-AD and AD absorb labels and messages
+// - PRF squeezes challenges
+// This file implements the Keccak-f[1600] permutation and that subset
+// of STROBE-128 following the reference Merlin implementation.
+
+const (
+	strobeR = 166
+
+	flagI = 1
+	flagA = 1 << 1
+	flagC = 1 << 2
+	flagT = 1 << 3
+	flagM = 1 << 4
+	flagK = 1 << 5
+)
+
+var keccakRoundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// keccakRotations[x+5y] is the rho rotation offset of lane (x,y)
+var keccakRotations = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// keccakF1600 applies the Keccak-f[1600] permutation to the state
+// where lane (x,y) is a[x+5y].
+func keccakF1600(a *[25]uint64) {
+
+	var c, d [5]uint64
+	var b [25]uint64
+
+	for round := 0; round < 24; round++ {
+		// theta
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d[x] = c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+		}
+		for i := range a {
+			a[i] ^= d[i%5]
+		}
+		// rho and pi
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(a[x+5*y], keccakRotations[x+5*y])
+			}
+		}
+		// chi
+		for y := 0; y < 5; y++ {
+			for x := 0; x < 5; x++ {
+				a[x+5*y] = b[x+5*y] ^ (^b[(x+1)%5+5*y] & b[(x+2)%5+5*y])
+			}
+		}
+		// iota
+		a[0] ^= keccakRoundConstants[round]
+	}
+}
+
+// strobe128 represents the state of a STROBE-128 instance
+type strobe128 struct {
+	state    [200]byte
+	pos      int
+	posBegin int
+	curFlags byte
+}
+
+// newStrobe128 initializes STROBE-128 with the given protocol label
+func newStrobe128(protocolLabel []byte) *strobe128 {
+
+	s := &strobe128{}
+	copy(s.state[0:6], []byte{1, strobeR + 2, 1, 0, 1, 96})
+	copy(s.state[6:18], []byte("STROBEv1.0.2"))
+	s.permute()
+
+	s.metaAD(protocolLabel, false)
+	return s
+}
+
+// metaAD absorbs framing data
+func (s *strobe128) metaAD(data []byte, more bool) {
+	s.beginOp(flagM|flagA, more)
+	s.absorb(data)
+}
+
+// ad absorbs associated data
+func (s *strobe128) ad(data []byte, more bool) {
+	s.beginOp(flagA, more)
+	s.absorb(data)
+}
+
+// prf squeezes n pseudo-random bytes
+func (s *strobe128) prf(n int, more bool) []byte {
+	s.beginOp(flagI|flagA|flagC, more)
+	return s.squeeze(n)
+}
+
+func (s *strobe128) permute() {
+
+	var lanes [25]uint64
+	for i := range lanes {
+		lanes[i] = binary.LittleEndian.Uint64(s.state[8*i:])
+	}
+	keccakF1600(&lanes)
+	for i, lane := range lanes {
+		binary.LittleEndian.PutUint64(s.state[8*i:], lane)
+	}
+}
+
+func (s *strobe128) runF() {
+	s.state[s.pos] ^= byte(s.posBegin)
+	s.state[s.pos+1] ^= 0x04
+	s.state[strobeR+1] ^= 0x80
+	s.permute()
+	s.pos = 0
+	s.posBegin = 0
+}
+
+func (s *strobe128) absorb(data []byte) {
+	for _, b := range data {
+		s.state[s.pos] ^= b
+		s.pos++
+		if s.pos == strobeR {
+			s.runF()
+		}
+	}
+}
+
+func (s *strobe128) squeeze(n int) []byte {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = s.state[s.pos]
+		s.state[s.pos] = 0
+		s.pos++
+		if s.pos == strobeR {
+			s.runF()
+		}
+	}
+	return out
+}
+
+func (s *strobe128) beginOp(flags byte, more bool) {
+
+	if more {
+		if s.curFlags != flags {
+			panic("strobe operation continued with different flags")
+		}
+		return
+	}
+	if flags&flagT != 0 {
+		panic("strobe transport operations are not supported")
+	}
+
+	oldBegin := s.posBegin
+	s.posBegin = s.pos + 1
+	s.curFlags = flags
+
+	s.absorb([]byte{byte(oldBegin), flags})
+
+	forceF := flags&(flagC|flagK) != 0
+	if forceF && s.pos != 0 {
+		s.runF()
+	}
+}
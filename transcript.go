@@ -0,0 +1,198 @@
+package zkstarks
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// A Channel derives challenges and logs every operation in the same place,
+// a verifier holding a proof has to rebuild the prover's log to replay it.
+// The Transcript interface separates both roles :
+// - the prover transcript absorbs messages and records them in a Proof
+// - the verifier transcript reads the messages back from a Proof and absorbs
+// them the same way, so both sides derive identical challenges.
+// Protocols written against Transcript run unchanged as prover and verifier,
+// the prover passes the messages it computed to Message while the verifier
+// passes nil and uses the returned message.
+
+// ProofMessage represents a labelled prover message
+type ProofMessage struct {
+	Label string
+	Data  []byte
+}
+
+// Proof represents the messages sent by the prover, the hash function
+// used by the transcript is recorded in the header.
+type Proof struct {
+	Hash     string
+	Messages []ProofMessage
+}
+
+// Transcript represents one side of a Fiat-Shamir transcript
+type Transcript interface {
+	// Message absorbs a prover message, the prover records data while the
+	// verifier ignores it and returns the next message of the proof.
+	Message(label string, data []byte) ([]byte, error)
+	// ChallengeBytes derives n challenge bytes
+	ChallengeBytes(label string, n int) []byte
+	// RandInt derives a uniform integer in [min,max]
+	RandInt(label string, min, max *big.Int) *big.Int
+	// RandInts derives n uniform integers in [min,max]
+	RandInts(label string, n int, min, max *big.Int) []*big.Int
+	// RandFE derives a uniform field element
+	RandFE(label string, m *big.Int) *big.Int
+	// RandFEs derives n uniform field elements
+	RandFEs(label string, n int, m *big.Int) []*big.Int
+	// State returns the state challenges are derived from
+	State() []byte
+	// Hasher returns the hash function of the transcript
+	Hasher() Hasher
+}
+
+// challenger derives the challenges of both transcript sides from a channel
+type challenger struct {
+	channel *Channel
+}
+
+// ChallengeBytes derives n challenge bytes
+func (c challenger) ChallengeBytes(label string, n int) []byte {
+	return c.channel.ChallengeBytes(label, n)
+}
+
+// RandInt derives a uniform integer in [min,max]
+func (c challenger) RandInt(label string, min, max *big.Int) *big.Int {
+	return c.channel.RandInt(label, min, max)
+}
+
+// RandInts derives n uniform integers in [min,max]
+func (c challenger) RandInts(label string, n int, min, max *big.Int) []*big.Int {
+	return c.channel.RandInts(label, n, min, max)
+}
+
+// RandFE derives a uniform field element
+func (c challenger) RandFE(label string, m *big.Int) *big.Int {
+	return c.channel.RandFE(label, m)
+}
+
+// RandFEs derives n uniform field elements
+func (c challenger) RandFEs(label string, n int, m *big.Int) []*big.Int {
+	return c.channel.RandFEs(label, n, m)
+}
+
+// State returns the state challenges are derived from
+func (c challenger) State() []byte {
+	return c.channel.State
+}
+
+// Hasher returns the hash function of the transcript
+func (c challenger) Hasher() Hasher {
+	return c.channel.Hasher()
+}
+
+// ProverTranscript records the prover's messages into a proof
+type ProverTranscript struct {
+	challenger
+	proof *Proof
+}
+
+// NewProverTranscript creates a prover transcript using the given hash function
+func NewProverTranscript(h Hasher) *ProverTranscript {
+	return &ProverTranscript{
+		challenger: challenger{NewChannelWithHasher(h)},
+		proof:      &Proof{Hash: h.Name()},
+	}
+}
+
+// Message absorbs and records a prover message
+func (tr *ProverTranscript) Message(label string, data []byte) ([]byte, error) {
+	tr.proof.Messages = append(tr.proof.Messages, ProofMessage{Label: label, Data: data})
+	tr.channel.Send(label, data)
+	return data, nil
+}
+
+// Proof returns the proof recorded so far
+func (tr *ProverTranscript) Proof() *Proof {
+	return tr.proof
+}
+
+// channelTranscript returns a prover transcript over an existing channel so
+// that protocols written against Transcript extend the channel's log.
+func channelTranscript(channel *Channel) *ProverTranscript {
+	return &ProverTranscript{
+		challenger: challenger{channel},
+		proof:      &Proof{Hash: channel.Hasher().Name()},
+	}
+}
+
+// proverMessage sends a prover message, the prover transcript never fails
+// so an error means a verifier transcript was passed to the prover.
+func proverMessage(tr Transcript, label string, data []byte) {
+	if _, err := tr.Message(label, data); err != nil {
+		panic(err)
+	}
+}
+
+// VerifierTranscript reads the prover's messages from a proof
+type VerifierTranscript struct {
+	challenger
+	proof *Proof
+	next  int
+}
+
+// NewVerifierTranscript creates a verifier transcript over the proof using
+// the hash function recorded in its header.
+func NewVerifierTranscript(proof *Proof) (*VerifierTranscript, error) {
+
+	h, err := HasherFromName(proof.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VerifierTranscript{
+		challenger: challenger{NewChannelWithHasher(h)},
+		proof:      proof,
+	}, nil
+}
+
+// Message reads and absorbs the next message of the proof which must
+// carry the given label.
+func (tr *VerifierTranscript) Message(label string, _ []byte) ([]byte, error) {
+
+	data, err := readProofMessage(tr.proof, &tr.next, label)
+	if err != nil {
+		return nil, err
+	}
+	tr.channel.Send(label, data)
+
+	return data, nil
+}
+
+// Finalize checks that every message of the proof was read
+func (tr *VerifierTranscript) Finalize() error {
+	return finalizeProof(tr.proof, tr.next)
+}
+
+// readProofMessage returns the message of the proof at next which must carry
+// the given label and advances next.
+func readProofMessage(proof *Proof, next *int, label string) ([]byte, error) {
+
+	if *next >= len(proof.Messages) {
+		return nil, errors.New("proof has no more messages")
+	}
+	msg := proof.Messages[*next]
+	if msg.Label != label {
+		return nil, fmt.Errorf("expected message %s got %s", label, msg.Label)
+	}
+	*next++
+
+	return msg.Data, nil
+}
+
+// finalizeProof checks that the messages of the proof were read up to next
+func finalizeProof(proof *Proof, next int) error {
+	if next != len(proof.Messages) {
+		return fmt.Errorf("proof has %d unread messages", len(proof.Messages)-next)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package zkstarks
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/actuallyachraf/algebra/nt"
+	"github.com/stretchr/testify/assert"
+)
+
+// testProtocol commits to a value then answers a challenge, it is written
+// once and run by both the prover and the verifier.
+func testProtocol(tr Transcript, secret *big.Int) ([]*big.Int, error) {
+
+	var commitment []byte
+	if secret != nil {
+		commitment = NewSHA3Hasher().Hash(secret.Bytes())
+	}
+	if _, err := tr.Message("commitment", commitment); err != nil {
+		return nil, err
+	}
+	beta := tr.RandFE("beta", PrimeField.Modulus())
+
+	var answer []byte
+	if secret != nil {
+		answer = new(big.Int).Mul(secret, beta).Bytes()
+	}
+	answer, err := tr.Message("answer", answer)
+	if err != nil {
+		return nil, err
+	}
+	queries := tr.RandInts("query.index", 4, nt.FromInt64(0), nt.FromInt64(1023))
+
+	return append([]*big.Int{beta, new(big.Int).SetBytes(answer)}, queries...), nil
+}
+
+func TestTranscript(t *testing.T) {
+
+	t.Run("TestProverVerifierAgree", func(t *testing.T) {
+		prover := NewProverTranscript(NewBlake2sHasher())
+		expected, err := testProtocol(prover, big.NewInt(42))
+		assert.NoError(t, err)
+
+		proof := prover.Proof()
+		assert.Equal(t, "blake2s-256", proof.Hash)
+		assert.Len(t, proof.Messages, 2)
+
+		verifier, err := NewVerifierTranscript(proof)
+		assert.NoError(t, err)
+		actual, err := testProtocol(verifier, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+		assert.NoError(t, verifier.Finalize())
+	})
+	t.Run("TestTamperedProof", func(t *testing.T) {
+		prover := NewProverTranscript(NewSHA3Hasher())
+		expected, err := testProtocol(prover, big.NewInt(42))
+		assert.NoError(t, err)
+
+		proof := prover.Proof()
+		proof.Messages[0].Data = append([]byte{1}, proof.Messages[0].Data[1:]...)
+		verifier, err := NewVerifierTranscript(proof)
+		assert.NoError(t, err)
+		actual, err := testProtocol(verifier, nil)
+		assert.NoError(t, err)
+		assert.NotEqual(t, expected[0], actual[0])
+	})
+	t.Run("TestMalformedProof", func(t *testing.T) {
+		_, err := NewVerifierTranscript(&Proof{Hash: "md5"})
+		assert.Error(t, err)
+
+		verifier, err := NewVerifierTranscript(&Proof{Hash: "sha3-256"})
+		assert.NoError(t, err)
+		_, err = testProtocol(verifier, nil)
+		assert.Error(t, err)
+
+		proof := &Proof{Hash: "sha3-256", Messages: []ProofMessage{{Label: "answer"}}}
+		verifier, err = NewVerifierTranscript(proof)
+		assert.NoError(t, err)
+		_, err = testProtocol(verifier, nil)
+		assert.Error(t, err)
+		assert.Error(t, verifier.Finalize())
+	})
+}